@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"the-machine/machine"
+	"the-machine/machine/debug"
+	"the-machine/machine/device"
+	"the-machine/machine/memory"
+)
+
+// Config describes machine setup, loaded from JSON:
+//
+//	{
+//	  "memory": {"ram": 2048, "rom": 2048},
+//	  "stack": 255,
+//	  "frequency": 0,
+//	  "devices": [{"type": "vga"}, {"type": "io", "params": {"sandbox": "files"}}, {"type": "file", "path": "state.bin", "size": 256}, {"type": "timer", "params": {"clock": "virtual"}}],
+//	  "descriptors": [{"fd": 13, "path": "input.txt", "mode": "r"}, {"fd": 14, "path": "-", "mode": "w"}],
+//	  "bus": false,
+//	  "program": {"path": "program.asc", "format": "ascii", "at": 0}
+//	}
+type Config struct {
+	Memory      MemoryConfig       `json:"memory"`
+	Stack       int                `json:"stack"`
+	Frequency   uint64             `json:"frequency"`
+	Devices     []DeviceConfig     `json:"devices"`
+	Descriptors []DescriptorConfig `json:"descriptors"`
+	Bus         bool               `json:"bus"`
+	Program     ProgramConfig      `json:"program"`
+}
+
+type MemoryConfig struct {
+	Ram int `json:"ram"`
+	Rom int `json:"rom"`
+}
+
+// DeviceConfig attaches memory file ("file" type), or registered device by name
+type DeviceConfig struct {
+	Type   string        `json:"type"`
+	Path   string        `json:"path"`
+	Size   int           `json:"size"`
+	Params device.Params `json:"params"`
+}
+
+type DescriptorConfig struct {
+	Fd   device.FileDescriptor `json:"fd"`
+	Path string                `json:"path"`
+	Mode string                `json:"mode"`
+}
+
+type ProgramConfig struct {
+	Path   string         `json:"path"`
+	Format string         `json:"format"`
+	At     memory.Address `json:"at"`
+}
+
+// DefaultConfig matches machine.NewMachine(2048)
+func DefaultConfig() Config {
+	return Config{
+		Memory:  MemoryConfig{Ram: 2048, Rom: 2048},
+		Devices: []DeviceConfig{{Type: "vga"}, {Type: "io"}},
+	}
+}
+
+func LoadConfig(fname string) (Config, error) {
+	cfg := DefaultConfig()
+	raw, err := os.ReadFile(fname)
+	if err != nil {
+		return cfg, fmt.Errorf("unable to read config %s: %w", fname, err)
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, fmt.Errorf("unable to parse config %s: %w", fname, err)
+	}
+	return cfg, nil
+}
+
+// Setup is a machine built from config, along with
+// host resources to release once done
+type Setup struct {
+	Machine machine.Machine
+	closers []io.Closer
+}
+
+type closerFunc func() error
+
+func (x closerFunc) Close() error {
+	return x()
+}
+
+func (x *Setup) Close() error {
+	first := x.Machine.DetachDevices()
+	for _, closer := range x.closers {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	x.closers = nil
+	return first
+}
+
+func (x Config) Build() (*Setup, error) {
+	setup := &Setup{}
+	if x.Memory.Ram <= 0 || x.Memory.Rom <= 0 {
+		return setup, fmt.Errorf("invalid memory sizes: RAM %d, ROM %d", x.Memory.Ram, x.Memory.Rom)
+	}
+	setup.Machine = machine.NewWithMemoryMap(machine.MemoryMap{
+		memory.RAM: memory.NewPagedMemory(x.Memory.Ram),
+		memory.ROM: memory.NewRom(x.Memory.Rom),
+	})
+	vm := &setup.Machine
+	for _, dev := range x.Devices {
+		if err := dev.attach(vm, setup); err != nil {
+			setup.Close()
+			return setup, err
+		}
+	}
+
+	if x.Stack > 0 {
+		if err := vm.SetStackSize(x.Stack); err != nil {
+			setup.Close()
+			return setup, fmt.Errorf("unable to set stack size: %w", err)
+		}
+	}
+	if x.Frequency > 0 {
+		vm.SetFrequency(x.Frequency)
+	}
+	if len(x.Descriptors) > 0 {
+		iomap, err := vm.GetIO()
+		if err != nil {
+			setup.Close()
+			return setup, fmt.Errorf("descriptors need io device: %w", err)
+		}
+		setup.closers = append(setup.closers, closerFunc(iomap.CloseAll))
+		for _, fd := range x.Descriptors {
+			if err := fd.attach(iomap); err != nil {
+				setup.Close()
+				return setup, err
+			}
+		}
+	}
+	if x.Bus {
+		if _, err := vm.AttachDefaultBus(); err != nil {
+			setup.Close()
+			return setup, fmt.Errorf("unable to attach bus: %w", err)
+		}
+	}
+	if x.Program.Path != "" {
+		if err := x.Program.load(vm); err != nil {
+			setup.Close()
+			return setup, err
+		}
+	}
+
+	return setup, nil
+}
+
+func (x DeviceConfig) attach(vm *machine.Machine, setup *Setup) error {
+	if x.Type == "file" {
+		file, err := memory.OpenFile(x.Path, x.Size)
+		if err != nil {
+			return fmt.Errorf("unable to attach file memory: %w", err)
+		}
+		setup.closers = append(setup.closers, file)
+		vm.AttachMemory(memory.Persisted, file)
+		return nil
+	}
+	if _, err := vm.AttachRegistered(x.Type, x.Params); err != nil {
+		return fmt.Errorf("unable to attach %q device: %w", x.Type, err)
+	}
+	return nil
+}
+
+// ParseDescriptor parses descriptor mapping in "fd=path:mode" form, e.g. "13=input.txt:r"
+func ParseDescriptor(spec string) (DescriptorConfig, error) {
+	var fd DescriptorConfig
+	eq := strings.Index(spec, "=")
+	colon := strings.LastIndex(spec, ":")
+	if eq < 1 || colon < eq+2 {
+		return fd, fmt.Errorf("invalid descriptor %q, expected fd=path:mode", spec)
+	}
+	num, err := strconv.ParseUint(spec[:eq], 10, 8)
+	if err != nil {
+		return fd, fmt.Errorf("invalid descriptor number in %q: %w", spec, err)
+	}
+	fd.Fd = device.FileDescriptor(num)
+	fd.Path = spec[eq+1 : colon]
+	fd.Mode = spec[colon+1:]
+	return fd, nil
+}
+
+func (x DescriptorConfig) String() string {
+	return fmt.Sprintf("%d=%s:%s", x.Fd, x.Path, x.Mode)
+}
+
+// attach opens descriptor and hands it over to the IO map,
+// which then closes it along with the rest
+func (x DescriptorConfig) attach(iomap *device.IOMap) error {
+	var access device.AccessType
+	var std *os.File
+	switch x.Mode {
+	case "r":
+		access, std = device.Read, os.Stdin
+	case "w":
+		access, std = device.Write, os.Stdout
+	default:
+		return fmt.Errorf("unknown mode %q for %s", x.Mode, x.Fd)
+	}
+	if x.Path == "-" {
+		iomap.SetDescriptor(x.Fd, device.NewFilelike(x.Fd, access, std))
+		return nil
+	}
+	file, err := device.OpenFile(x.Fd, x.Path, access)
+	if err != nil {
+		return fmt.Errorf("unable to open %s for %s: %w", x.Path, x.Fd, err)
+	}
+	iomap.SetDescriptor(x.Fd, file)
+	return nil
+}
+
+func (x ProgramConfig) load(vm *machine.Machine) error {
+	var loader debug.Dumpable
+	switch x.Format {
+	case "", "ascii":
+		loader = debug.NewAsciiLoader(x.Path, debug.Decimal)
+	case "binary":
+		loader = debug.NewBinaryLoader(x.Path)
+	default:
+		return fmt.Errorf("unknown program format: %q", x.Format)
+	}
+	program, err := loader.Load()
+	if err != nil {
+		return fmt.Errorf("unable to load program: %w", err)
+	}
+	if err := vm.LoadProgram(x.At, program); err != nil {
+		return fmt.Errorf("unable to load program: %w", err)
+	}
+	return nil
+}
+
+// RunConfig builds machine from config file and runs it
+func RunConfig(fname string, opts ...Option) {
+	cfg, err := LoadConfig(fname)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	setup, err := cfg.Build()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer setup.Close()
+	if _, err := Run(setup.Machine, opts...); err != nil {
+		setup.Machine.DebugError(err)
+	}
+}
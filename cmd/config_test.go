@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+func Test_Config_Build(t *testing.T) {
+	dir := t.TempDir()
+	program := [][]byte{
+		instruction.MOV_LIT_BNK.Pack(uint16(memory.DeviceIO)),
+		instruction.MOV_LIT_R1.Pack(13),
+		instruction.MOV_MEM_REG.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+		instruction.MOV_LIT_AC.Pack(14),
+		instruction.MOV_REG_MEM.Pack(register.R2.AsUint16()),
+		instruction.HALT.Pack(0),
+	}
+	var image []byte
+	for _, instr := range program {
+		image = append(image, instr...)
+	}
+	os.WriteFile(filepath.Join(dir, "program.bin"), image, 0644)
+	os.WriteFile(filepath.Join(dir, "input.txt"), []byte("hai"), 0644)
+	os.WriteFile(filepath.Join(dir, "machine.json"), []byte(`{
+		"memory": {"ram": 256, "rom": 128},
+		"stack": 64,
+		"devices": [{"type": "io"}],
+		"descriptors": [
+			{"fd": 13, "path": "`+filepath.Join(dir, "input.txt")+`", "mode": "r"},
+			{"fd": 14, "path": "`+filepath.Join(dir, "out.txt")+`", "mode": "w"}
+		],
+		"program": {"path": "`+filepath.Join(dir, "program.bin")+`", "format": "binary"}
+	}`), 0644)
+
+	cfg, err := LoadConfig(filepath.Join(dir, "machine.json"))
+	if err != nil {
+		t.Fatalf("unable to load config: %v", err)
+	}
+	setup, err := cfg.Build()
+	if err != nil {
+		t.Fatalf("unable to build machine: %v", err)
+	}
+	if _, err := setup.Machine.GetBank(memory.DeviceVGA); err == nil {
+		t.Fatalf("expected only configured devices to be attached")
+	}
+	if _, err := Run(setup.Machine); err != nil {
+		t.Fatalf("error running configured machine: %v", err)
+	}
+	setup.Close()
+
+	if out, _ := os.ReadFile(filepath.Join(dir, "out.txt")); string(out) != "h\x00" {
+		t.Fatalf("expected program to copy from input to output, got %q", out)
+	}
+}
+
+func Test_Config_Invalid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Devices = append(cfg.Devices, DeviceConfig{Type: "teleporter"})
+	if _, err := cfg.Build(); err == nil {
+		t.Fatalf("expected error building machine with unknown device")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Devices = nil
+	cfg.Descriptors = []DescriptorConfig{{Fd: 13, Path: "-", Mode: "r"}}
+	if _, err := cfg.Build(); err == nil {
+		t.Fatalf("expected error mapping descriptors without io device")
+	}
+}
@@ -2,12 +2,100 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"the-machine/machine"
 	"the-machine/machine/debug"
+	"the-machine/machine/device"
+	"the-machine/machine/memory"
 )
 
-func Run(vm machine.Machine) (int, error) {
-	step := 0
+const heatmapWidth = 64
+
+type options struct {
+	heatmap    bool
+	heatmapCsv string
+	screenshot string
+	fds        []DescriptorConfig
+	frequency  *uint64
+}
+
+type Option func(*options)
+
+// WithHeatmap renders RAM and ROM access heatmap after the run
+func WithHeatmap() Option {
+	return func(o *options) {
+		o.heatmap = true
+	}
+}
+
+// WithHeatmapCSV exports per-address access counters to file after the run
+func WithHeatmapCSV(fname string) Option {
+	return func(o *options) {
+		o.heatmapCsv = fname
+	}
+}
+
+// WithScreenshot exports video frame to PNG file after the run
+func WithScreenshot(fname string) Option {
+	return func(o *options) {
+		o.screenshot = fname
+	}
+}
+
+// WithFrequency throttles the run to hz cycles per second, 0 runs at host speed
+func WithFrequency(hz uint64) Option {
+	return func(o *options) {
+		o.frequency = &hz
+	}
+}
+
+// WithDescriptors maps host files, or stdio for "-" path, to guest descriptors.
+// Mapped descriptors are closed once the machine halts, stdio stays open,
+// and whatever the descriptors held before the run is put back.
+func WithDescriptors(fds ...DescriptorConfig) Option {
+	return func(o *options) {
+		o.fds = append(o.fds, fds...)
+	}
+}
+
+func Run(vm machine.Machine, opts ...Option) (step int, err error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.frequency != nil {
+		vm.SetFrequency(*o.frequency)
+	}
+
+	if len(o.fds) > 0 {
+		iomap, err := vm.GetIO()
+		if err != nil {
+			return 0, fmt.Errorf("descriptors need io device: %w", err)
+		}
+		mapped := newMappedDescriptors(iomap)
+		defer func() {
+			if cerr := mapped.release(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+		for _, fd := range o.fds {
+			if err := mapped.attach(fd); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var heatmap *debug.Heatmap
+	if o.heatmap || o.heatmapCsv != "" {
+		heatmap = debug.NewHeatmap()
+		for _, kind := range []memory.MemoryType{memory.RAM, memory.ROM} {
+			if err := vm.Observe(kind, heatmap); err != nil {
+				return 0, fmt.Errorf("unable to collect %s stats: %w", kind, err)
+			}
+		}
+	}
+
 	for step < 0xffff {
 		if err := vm.Tick(); err != nil {
 			terr := fmt.Errorf("error at tick %d: %w", step, err)
@@ -19,10 +107,107 @@ func Run(vm machine.Machine) (int, error) {
 			break
 		}
 	}
+
+	if heatmap != nil {
+		if err := report(heatmap, o); err != nil {
+			return step, err
+		}
+	}
+	if o.screenshot != "" {
+		if err := screenshot(vm, o.screenshot); err != nil {
+			return step, err
+		}
+	}
 	return step, nil
 }
 
-func RunFile(fname string) {
+// mappedDescriptors keeps track of descriptors mapped for the run,
+// along with what they replaced
+type mappedDescriptors struct {
+	iomap    *device.IOMap
+	attached []device.FileDescriptor
+	previous map[device.FileDescriptor]device.Filelike
+}
+
+func newMappedDescriptors(iomap *device.IOMap) *mappedDescriptors {
+	return &mappedDescriptors{iomap: iomap, previous: map[device.FileDescriptor]device.Filelike{}}
+}
+
+func (x *mappedDescriptors) attach(fd DescriptorConfig) error {
+	for _, attached := range x.attached {
+		if attached == fd.Fd {
+			return fmt.Errorf("%s is mapped more than once", fd.Fd)
+		}
+	}
+	previous, ok := x.iomap.GetDescriptor(fd.Fd)
+	if err := fd.attach(x.iomap); err != nil {
+		return err
+	}
+	if ok {
+		x.previous[fd.Fd] = previous
+	}
+	x.attached = append(x.attached, fd.Fd)
+	return nil
+}
+
+// release closes mapped descriptors and puts back the ones they replaced,
+// reporting all close errors
+func (x *mappedDescriptors) release() error {
+	var err error
+	for _, fd := range x.attached {
+		if cerr := x.iomap.CloseDescriptor(fd); cerr != nil {
+			if err == nil {
+				err = fmt.Errorf("unable to close %s: %w", fd, cerr)
+			} else {
+				err = fmt.Errorf("%v; unable to close %s: %w", err, fd, cerr)
+			}
+		}
+	}
+	for fd, previous := range x.previous {
+		x.iomap.SetDescriptor(fd, previous)
+	}
+	return err
+}
+
+func screenshot(vm machine.Machine, fname string) error {
+	vga, err := vm.GetBank(memory.DeviceVGA)
+	if err != nil {
+		return fmt.Errorf("unable to take screenshot: %w", err)
+	}
+	video, ok := memory.Unwrap(vga).(device.Video)
+	if !ok {
+		return fmt.Errorf("unable to take screenshot: %s is not a video device", memory.DeviceVGA)
+	}
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("unable to take screenshot: %w", err)
+	}
+	defer f.Close()
+	return video.WritePNG(f)
+}
+
+func report(heatmap *debug.Heatmap, o options) error {
+	if o.heatmap {
+		renderer := debug.NewRenderer(debug.Formatter{Numbers: debug.Decimal, OutputAs: debug.Uint})
+		for _, kind := range heatmap.Banks() {
+			renderer.Out(fmt.Sprintf("[ %s heatmap ]", kind))
+			renderer.Out(renderer.Heatmap(heatmap, kind, heatmapWidth))
+		}
+	}
+	if o.heatmapCsv != "" {
+		f, err := os.Create(o.heatmapCsv)
+		if err != nil {
+			return fmt.Errorf("unable to export heatmap: %w", err)
+		}
+		defer f.Close()
+		if err := heatmap.WriteCSV(f); err != nil {
+			return fmt.Errorf("unable to export heatmap: %w", err)
+		}
+	}
+	return nil
+}
+
+func RunFile(fname string, opts ...Option) {
 	vm := machine.NewMachine(2048)
 	loader := debug.NewAsciiLoader(fname, debug.Decimal)
 	if program, err := loader.Load(); err != nil {
@@ -31,7 +216,7 @@ func RunFile(fname string) {
 	} else {
 		vm.LoadProgram(0, program)
 	}
-	if _, err := Run(vm); err != nil {
+	if _, err := Run(vm, opts...); err != nil {
 		vm.DebugError(err)
 		return
 	}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"the-machine/machine"
+	"the-machine/machine/device"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+	"time"
+)
+
+func Test_ParseDescriptor(t *testing.T) {
+	suite := map[string]DescriptorConfig{
+		"13=input.txt:r":   {Fd: 13, Path: "input.txt", Mode: "r"},
+		"14=out.log:w":     {Fd: 14, Path: "out.log", Mode: "w"},
+		"15=-:r":           {Fd: 15, Path: "-", Mode: "r"},
+		"16=c:\\in.txt:r":  {Fd: 16, Path: "c:\\in.txt", Mode: "r"},
+		"17=dir/a=b.txt:w": {Fd: 17, Path: "dir/a=b.txt", Mode: "w"},
+	}
+	for spec, expected := range suite {
+		fd, err := ParseDescriptor(spec)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", spec, err)
+		}
+		if fd != expected {
+			t.Fatalf("expected %q to parse as %v, got %v", spec, expected, fd)
+		}
+		if fd.String() != spec {
+			t.Fatalf("expected %q to round-trip, got %q", spec, fd.String())
+		}
+	}
+	for _, spec := range []string{"", "13", "13=input.txt", "=input.txt:r", "13=:r", "256=input.txt:r", "x=input.txt:r"} {
+		if _, err := ParseDescriptor(spec); err == nil {
+			t.Fatalf("expected error parsing %q", spec)
+		}
+	}
+}
+
+func Test_Run_WithDescriptors(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	output := filepath.Join(dir, "out.log")
+	os.WriteFile(input, []byte("hai"), 0644)
+
+	vm := machine.NewMachine(256)
+	var program []byte
+	for _, instr := range [][]byte{
+		instruction.MOV_LIT_BNK.Pack(uint16(memory.DeviceIO)),
+		instruction.MOV_LIT_R1.Pack(13),
+		instruction.MOV_MEM_REG.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+		instruction.MOV_LIT_AC.Pack(14),
+		instruction.MOV_REG_MEM.Pack(register.R2.AsUint16()),
+		instruction.HALT.Pack(0),
+	} {
+		program = append(program, instr...)
+	}
+	vm.LoadProgram(0, program)
+
+	iomap, _ := vm.GetIO()
+	iomap.SetDescriptor(20, device.NewFilelike(20, device.Write, &strings.Builder{}))
+	fds := []DescriptorConfig{
+		{Fd: 13, Path: input, Mode: "r"},
+		{Fd: 14, Path: output, Mode: "w"},
+		{Fd: 15, Path: "-", Mode: "w"},
+		{Fd: 16, Path: "-", Mode: "r"},
+	}
+	if _, err := Run(vm, WithDescriptors(fds...)); err != nil {
+		t.Fatalf("unexpected run error: %v", err)
+	}
+	if out, _ := os.ReadFile(output); string(out) != "h\x00" {
+		t.Fatalf("expected program to copy from input to output, got %q", out)
+	}
+
+	if _, err := iomap.GetByte(memory.Address(13)); err == nil {
+		t.Fatalf("expected descriptors to be closed once machine halts")
+	}
+	if _, err := os.Stdout.Write(nil); err != nil {
+		t.Fatalf("expected process stdout to stay open: %v", err)
+	}
+	if _, err := os.Stdin.Stat(); err != nil {
+		t.Fatalf("expected process stdin to stay open: %v", err)
+	}
+	if err := iomap.SetByte(memory.Address(20), 'x'); err != nil {
+		t.Fatalf("expected caller registered descriptor to stay open: %v", err)
+	}
+	iomap.SetUint16(device.IoDescriptor, uint16(device.Stdout))
+	if status, _ := iomap.GetUint16(device.IoStatus); status&device.FdOpen == 0 {
+		t.Fatalf("expected stdout to stay open")
+	}
+
+	bad := []DescriptorConfig{{Fd: 13, Path: filepath.Join(dir, "missing.txt"), Mode: "r"}}
+	if _, err := Run(vm, WithDescriptors(bad...)); err == nil {
+		t.Fatalf("expected error mapping missing file")
+	}
+	twice := []DescriptorConfig{{Fd: 14, Path: output, Mode: "w"}, {Fd: 14, Path: output, Mode: "w"}}
+	if _, err := Run(vm, WithDescriptors(twice...)); err == nil {
+		t.Fatalf("expected error mapping descriptor twice")
+	}
+	if _, ok := iomap.GetDescriptor(14); ok {
+		t.Fatalf("expected descriptor mapped before error to be closed")
+	}
+}
+
+func Test_Run_RemapStdio(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.log")
+	vm := machine.NewMachine(256)
+	vm.LoadProgram(0, append(append(append(
+		instruction.MOV_LIT_BNK.Pack(uint16(memory.DeviceIO)),
+		instruction.MOV_LIT_AC.Pack(uint16(device.Stdout))...),
+		instruction.MOV_LIT_MEM.Pack('x')...),
+		instruction.HALT.Pack(0)...))
+
+	iomap, _ := vm.GetIO()
+	stdout, _ := iomap.GetDescriptor(device.Stdout)
+	if _, err := Run(vm, WithDescriptors(DescriptorConfig{Fd: device.Stdout, Path: output, Mode: "w"})); err != nil {
+		t.Fatalf("unexpected run error: %v", err)
+	}
+	if out, _ := os.ReadFile(output); string(out) != "x\x00" {
+		t.Fatalf("expected guest stdout to go to file, got %q", out)
+	}
+	if restored, ok := iomap.GetDescriptor(device.Stdout); !ok || restored != stdout {
+		t.Fatalf("expected original stdout to be restored after run")
+	}
+}
+
+type failingCloser struct {
+	strings.Builder
+}
+
+func (x *failingCloser) Close() error {
+	return errors.New("disk full")
+}
+
+func Test_Run_DescriptorCloseErrors(t *testing.T) {
+	iomap := device.NewIoMap().(*device.IOMap)
+	mapped := newMappedDescriptors(iomap)
+	for _, fd := range []device.FileDescriptor{13, 14} {
+		iomap.SetDescriptor(fd, device.NewFilelike(fd, device.Write, &failingCloser{}))
+		mapped.attached = append(mapped.attached, fd)
+	}
+	err := mapped.release()
+	if err == nil {
+		t.Fatalf("expected close errors to be reported")
+	}
+	if strings.Count(err.Error(), "unable to close") != 2 {
+		t.Fatalf("expected both close errors to be reported, got %v", err)
+	}
+}
+
+func Test_Run_WithFrequency(t *testing.T) {
+	vm := machine.NewMachine(256)
+	vm.LoadProgram(0, append(append(
+		instruction.MOV_LIT_R1.Pack(13),
+		instruction.MOV_LIT_R2.Pack(12)...),
+		instruction.HALT.Pack(0)...))
+
+	started := time.Now()
+	if _, err := Run(vm, WithFrequency(100)); err != nil {
+		t.Fatalf("unable to run: %v", err)
+	}
+	if vm.GetFrequency() != 100 {
+		t.Fatalf("expected frequency to be set, got %d", vm.GetFrequency())
+	}
+	// Clock starts counting after the first instruction
+	cycles := vm.GetCycles() - instruction.Descriptors[instruction.MOV_LIT_R1].Cycles
+	expected := time.Duration(cycles)*time.Second/100 - time.Millisecond
+	if elapsed := time.Since(started); elapsed < expected {
+		t.Fatalf("expected run to be throttled to at least %v, took %v", expected, elapsed)
+	}
+}
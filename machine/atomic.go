@@ -0,0 +1,52 @@
+package machine
+
+import (
+	"sync"
+	"the-machine/machine/memory"
+)
+
+// lockedMemory serializes access to underlying memory, so that
+// concurrently running cores can't tear multi-byte accesses
+type lockedMemory struct {
+	mem  memory.MemoryAccess
+	lock *sync.Mutex
+}
+
+func (x lockedMemory) GetByte(at memory.Address) (byte, error) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.mem.GetByte(at)
+}
+
+func (x lockedMemory) GetUint16(at memory.Address) (uint16, error) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.mem.GetUint16(at)
+}
+
+func (x lockedMemory) SetByte(at memory.Address, value byte) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.mem.SetByte(at, value)
+}
+
+func (x lockedMemory) SetUint16(at memory.Address, value uint16) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.mem.SetUint16(at, value)
+}
+
+func (x lockedMemory) Atomically(fn func(memory.MemoryAccess) error) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return fn(x.mem)
+}
+
+// LockMemory blocks memory access for all cores sharing the memory map
+func (vm *Machine) LockMemory() {
+	vm.memoryLock.Lock()
+}
+
+func (vm *Machine) UnlockMemory() {
+	vm.memoryLock.Unlock()
+}
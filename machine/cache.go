@@ -0,0 +1,213 @@
+package machine
+
+import (
+	"fmt"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+)
+
+type Replacement uint8
+
+const (
+	LRU  Replacement = 0
+	FIFO Replacement = iota
+)
+
+func (x Replacement) String() string {
+	switch x {
+	case LRU:
+		return "LRU"
+	case FIFO:
+		return "FIFO"
+	default:
+		return fmt.Sprintf("unknown replacement: %d", x)
+	}
+}
+
+type cacheLine struct {
+	bank  memory.MemoryType
+	tag   int
+	valid bool
+	stamp uint64
+}
+
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+func (x CacheStats) String() string {
+	return fmt.Sprintf("hits: %d, misses: %d, evictions: %d", x.Hits, x.Misses, x.Evictions)
+}
+
+// Cache simulates set-associative cache in front of memory banks.
+// Only tags are tracked, data always goes through to memory.
+type Cache struct {
+	sets        [][]cacheLine
+	lineSize    int
+	replacement Replacement
+	clock       uint64
+	stats       CacheStats
+	hitCycles   uint64
+	missCycles  uint64
+	pending     uint64
+}
+
+func NewCache(sets int, ways int, lineSize int, replacement Replacement) (*Cache, error) {
+	if sets <= 0 || ways <= 0 || lineSize <= 0 {
+		return nil, internal.Error(fmt.Sprintf("invalid cache geometry: %d sets, %d ways, %d byte lines", sets, ways, lineSize), nil, internal.ErrorMemory)
+	}
+	cache := &Cache{
+		sets:        make([][]cacheLine, sets),
+		lineSize:    lineSize,
+		replacement: replacement,
+	}
+	for i := range cache.sets {
+		cache.sets[i] = make([]cacheLine, ways)
+	}
+	return cache, nil
+}
+
+// SetTiming enables timing model, charging cycles per cache hit and miss
+func (x *Cache) SetTiming(hit uint64, miss uint64) {
+	x.hitCycles = hit
+	x.missCycles = miss
+}
+
+func (x Cache) Stats() CacheStats {
+	return x.stats
+}
+
+// Flush invalidates all lines
+func (x *Cache) Flush() {
+	for _, set := range x.sets {
+		for i := range set {
+			set[i] = cacheLine{}
+		}
+	}
+}
+
+// cycles hands over timing cost accrued since last call
+func (x *Cache) cycles() uint64 {
+	cycles := x.pending
+	x.pending = 0
+	return cycles
+}
+
+func (x *Cache) access(bank memory.MemoryType, at memory.Address, size int) {
+	first := int(at) / x.lineSize
+	last := (int(at) + size - 1) / x.lineSize
+	for line := first; line <= last; line++ {
+		x.touch(bank, line)
+	}
+}
+
+func (x *Cache) touch(bank memory.MemoryType, line int) {
+	x.clock++
+	set := x.sets[line%len(x.sets)]
+	tag := line / len(x.sets)
+
+	for i, l := range set {
+		if l.valid && l.bank == bank && l.tag == tag {
+			x.stats.Hits++
+			x.pending += x.hitCycles
+			if x.replacement == LRU {
+				set[i].stamp = x.clock
+			}
+			return
+		}
+	}
+
+	// Oldest line goes first: least recently used for LRU, first filled for FIFO
+	victim := 0
+	for i, l := range set {
+		if !l.valid {
+			victim = i
+			break
+		}
+		if l.stamp < set[victim].stamp {
+			victim = i
+		}
+	}
+
+	x.stats.Misses++
+	x.pending += x.missCycles
+	if set[victim].valid {
+		x.stats.Evictions++
+	}
+	set[victim] = cacheLine{bank: bank, tag: tag, valid: true, stamp: x.clock}
+}
+
+type cachedMemory struct {
+	mem   memory.MemoryAccess
+	kind  memory.MemoryType
+	cache *Cache
+}
+
+func (x cachedMemory) Unwrap() memory.MemoryAccess {
+	return x.mem
+}
+
+func (x cachedMemory) GetByte(at memory.Address) (byte, error) {
+	value, err := x.mem.GetByte(at)
+	if err == nil {
+		x.cache.access(x.kind, at, 1)
+	}
+	return value, err
+}
+
+func (x cachedMemory) GetUint16(at memory.Address) (uint16, error) {
+	value, err := x.mem.GetUint16(at)
+	if err == nil {
+		x.cache.access(x.kind, at, 2)
+	}
+	return value, err
+}
+
+func (x cachedMemory) SetByte(at memory.Address, value byte) error {
+	err := x.mem.SetByte(at, value)
+	if err == nil {
+		x.cache.access(x.kind, at, 1)
+	}
+	return err
+}
+
+func (x cachedMemory) SetUint16(at memory.Address, value uint16) error {
+	err := x.mem.SetUint16(at, value)
+	if err == nil {
+		x.cache.access(x.kind, at, 2)
+	}
+	return err
+}
+
+// SetCache puts cache between CPU and memory, nil disables it
+func (vm *Machine) SetCache(cache *Cache) {
+	vm.cache = cache
+}
+
+func (vm Machine) GetCache() *Cache {
+	return vm.cache
+}
+
+// cached wraps bank memory with cache, if enabled. It goes below protection
+// and translation, so that lines are tagged by physical address and only
+// successful accesses are counted.
+func (vm *Machine) cached(kind memory.MemoryType, mem memory.MemoryAccess) memory.MemoryAccess {
+	if vm.cache == nil {
+		return mem
+	}
+	return cachedMemory{mem: mem, kind: kind, cache: vm.cache}
+}
+
+// SetCache gives each core its own cache of the same geometry
+func (vm *MultiCore) SetCache(sets int, ways int, lineSize int, replacement Replacement) error {
+	for _, core := range vm.cores {
+		cache, err := NewCache(sets, ways, lineSize, replacement)
+		if err != nil {
+			return err
+		}
+		core.SetCache(cache)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package machine
+
+import (
+	"testing"
+	"the-machine/machine/cpu"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+func Test_Cache_Replacement(t *testing.T) {
+	expected := map[Replacement]CacheStats{
+		LRU:  {Hits: 2, Misses: 3, Evictions: 1},
+		FIFO: {Hits: 1, Misses: 4, Evictions: 2},
+	}
+	for replacement, want := range expected {
+		cache, err := NewCache(1, 2, 4, replacement)
+		if err != nil {
+			t.Fatalf("unable to create cache: %v", err)
+		}
+		for _, at := range []memory.Address{0, 4, 1, 8, 2} {
+			cache.access(memory.RAM, at, 1)
+		}
+		if cache.Stats() != want {
+			t.Fatalf("%s: expected %v, got %v", replacement, want, cache.Stats())
+		}
+	}
+}
+
+func Test_Cache_Geometry(t *testing.T) {
+	if _, err := NewCache(0, 2, 4, LRU); err == nil {
+		t.Fatalf("expected error creating cache without sets")
+	}
+
+	cache, _ := NewCache(4, 1, 4, LRU)
+	cache.access(memory.RAM, 3, 2)
+	if cache.Stats().Misses != 2 {
+		t.Fatalf("expected access across line boundary to touch both lines, got %v", cache.Stats())
+	}
+	cache.access(memory.ROM, 3, 1)
+	if cache.Stats().Hits != 0 {
+		t.Fatalf("expected banks to be cached separately, got %v", cache.Stats())
+	}
+}
+
+func Test_Cache_Timing(t *testing.T) {
+	program := packProgram(
+		instruction.MOV_LIT_AC.Pack(100),
+		instruction.MOV_LIT_MEM.Pack(161),
+		instruction.MOV_LIT_MEM.Pack(13),
+	)
+	plain := NewMachine(255)
+	plain.LoadProgram(0, program)
+	run(plain)
+
+	vm := NewMachine(255)
+	vm.LoadProgram(0, program)
+	cache, _ := NewCache(4, 2, 8, LRU)
+	cache.SetTiming(1, 10)
+	vm.SetCache(cache)
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+
+	stats := cache.Stats()
+	if stats.Misses != 2 || stats.Hits != 4 {
+		t.Fatalf("expected instruction line and data line misses only, got %v", stats)
+	}
+	if vm.GetCycles() != plain.GetCycles()+stats.Hits+10*stats.Misses {
+		t.Fatalf("expected cache cost on top of %d cycles, got %d", plain.GetCycles(), vm.GetCycles())
+	}
+}
+
+func Test_Cache_PhysicalTags(t *testing.T) {
+	vm := NewMachine(2048)
+	vm.SetMMU(NewMMU(4))
+	cache, _ := NewCache(4, 2, 8, LRU)
+	vm.SetCache(cache)
+	ram, _ := vm.getMemory(memory.RAM)
+
+	task := packProgram(
+		instruction.MOV_LIT_AC.Pack(0x100+12),
+		instruction.MOV_REG_MEM.Pack(register.R3.AsUint16()),
+	)
+	// Same virtual layout, different physical pages
+	for ptb, pages := range map[uint16][]byte{0x400: {1, 2}, 0x480: {3, 4}} {
+		ram.SetUint16(memory.Address(ptb), PageEntry(pages[0], PagePresent|PageUser))
+		ram.SetUint16(memory.Address(ptb+2), PageEntry(pages[1], PagePresent|PageWritable|PageUser))
+		vm.LoadProgram(memory.Address(pages[0])*PageSize, task)
+	}
+
+	var misses []uint64
+	for _, ptb := range []uint16{0x400, 0x480} {
+		vm.Reset()
+		vm.cpu.SetRegister(register.Ptb, ptb)
+		vm.cpu.SetMode(cpu.User)
+		before := cache.Stats().Misses
+		if steps, err := run(vm); err != nil {
+			t.Fatalf("machine stuck (%d) or error running task: %v", steps, err)
+		}
+		misses = append(misses, cache.Stats().Misses-before)
+	}
+	if misses[0] == 0 || misses[1] != misses[0] {
+		t.Fatalf("expected second task to miss on its own physical lines, got %v", misses)
+	}
+
+	before := cache.Stats()
+	guarded := cachedMemory{mem: memory.NewMemory(4), kind: memory.RAM, cache: cache}
+	if _, err := guarded.GetUint16(100); err == nil {
+		t.Fatalf("expected error reading past memory end")
+	}
+	if cache.Stats() != before {
+		t.Fatalf("expected failed access not to be counted, got %v", cache.Stats())
+	}
+}
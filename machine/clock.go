@@ -0,0 +1,64 @@
+package machine
+
+import (
+	"time"
+)
+
+// throttleThreshold is the minimum lead over real time worth sleeping for
+const throttleThreshold = time.Millisecond
+
+// clock throttles execution to target frequency, in cycles per second.
+// Zero frequency runs unthrottled.
+type clock struct {
+	frequency uint64
+	start     time.Time
+	base      uint64
+	now       func() time.Time
+	sleep     func(time.Duration)
+}
+
+func newClock() *clock {
+	return &clock{now: time.Now, sleep: time.Sleep}
+}
+
+func (x *clock) reset() {
+	x.start = time.Time{}
+	x.base = 0
+}
+
+func (x *clock) throttle(cycles uint64) {
+	if x.frequency == 0 {
+		return
+	}
+	now := x.now()
+	if x.start.IsZero() {
+		x.start = now
+		x.base = cycles
+		return
+	}
+	expected := time.Duration(float64(cycles-x.base) / float64(x.frequency) * float64(time.Second))
+	if ahead := expected - now.Sub(x.start); ahead > throttleThreshold {
+		x.sleep(ahead)
+	}
+}
+
+// SetFrequency throttles real-time execution to hz cycles per second,
+// zero disables throttling
+func (vm *Machine) SetFrequency(hz uint64) {
+	vm.clock.frequency = hz
+	vm.clock.reset()
+}
+
+func (vm Machine) GetFrequency() uint64 {
+	return vm.clock.frequency
+}
+
+func (vm Machine) GetCycles() uint64 {
+	return vm.cpu.GetCycles()
+}
+
+func (vm *MultiCore) SetFrequency(hz uint64) {
+	for _, core := range vm.cores {
+		core.SetFrequency(hz)
+	}
+}
@@ -0,0 +1,90 @@
+package machine
+
+import (
+	"testing"
+	"the-machine/machine/cpu"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+	"time"
+)
+
+func Test_Machine_CountsCycles(t *testing.T) {
+	vm := NewMachine(255)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_R1.Pack(13),
+		instruction.MOV_LIT_R2.Pack(12),
+		instruction.MUL_REG_REG.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+		instruction.MOV_REG_REG.Pack(register.Cyc.AsUint16(), register.R3.AsUint16()),
+	))
+
+	if _, err := run(vm); err != nil {
+		t.Fatalf("error running program: %v", err)
+	}
+
+	expected := instruction.Descriptors[instruction.MOV_LIT_R1].Cycles +
+		instruction.Descriptors[instruction.MOV_LIT_R2].Cycles +
+		instruction.Descriptors[instruction.MUL_REG_REG].Cycles
+	if uint64(vm.cpu.GetRegister(register.R3)) != expected {
+		t.Fatalf("expected guest to read %d cycles, got %d", expected, vm.cpu.GetRegister(register.R3))
+	}
+
+	expected += instruction.Descriptors[instruction.MOV_REG_REG].Cycles +
+		instruction.Descriptors[instruction.NOP].Cycles // HALT
+	if vm.GetCycles() != expected {
+		t.Fatalf("expected %d cycles, got %d", expected, vm.GetCycles())
+	}
+
+	vm.Reset()
+	if vm.GetCycles() != 0 {
+		t.Fatalf("expected cycle counter to reset, got %d", vm.GetCycles())
+	}
+}
+
+func Test_Machine_CountsFetchFaultCycles(t *testing.T) {
+	vm := NewMachine(255)
+	vm.LoadProgram(100, packProgram())
+	vm.Protect(memory.ROM, 0, 99, NoExecute)
+	vm.SetTrapHandler(cpu.TrapProtection, 100)
+
+	if err := vm.Tick(); err != nil {
+		t.Fatalf("expected fetch fault to be handled, got %v", err)
+	}
+	if vm.cpu.GetRegister(register.Ip) != 100 {
+		t.Fatalf("expected fetch fault to enter handler, got Ip %d", vm.cpu.GetRegister(register.Ip))
+	}
+	if vm.GetCycles() != instruction.Descriptors[instruction.NOP].Cycles {
+		t.Fatalf("expected fetch fault to take cycles, got %d", vm.GetCycles())
+	}
+}
+
+func Test_Clock_Throttle(t *testing.T) {
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	clk := &clock{
+		frequency: 1000,
+		now:       func() time.Time { return now },
+		sleep:     func(d time.Duration) { slept += d; now = now.Add(d) },
+	}
+
+	clk.throttle(0)
+	clk.throttle(100) // 100 cycles at 1kHz = 100ms
+	if slept != 100*time.Millisecond {
+		t.Fatalf("expected to sleep 100ms, got %v", slept)
+	}
+
+	now = now.Add(time.Second)
+	clk.throttle(200) // running behind, no sleep
+	if slept != 100*time.Millisecond {
+		t.Fatalf("expected not to sleep when behind real time, got %v", slept)
+	}
+}
+
+func Test_Clock_Unthrottled(t *testing.T) {
+	clk := &clock{
+		now:   time.Now,
+		sleep: func(d time.Duration) { t.Fatalf("expected unthrottled clock not to sleep") },
+	}
+	clk.throttle(0)
+	clk.throttle(0xffff)
+}
@@ -0,0 +1,114 @@
+package cpu
+
+import (
+	"fmt"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+// ContextRegisters lists register file saved and loaded by context
+// switching, in the order of words in RAM context block
+var ContextRegisters = []register.Register{
+	register.R1,
+	register.R2,
+	register.R3,
+	register.R4,
+	register.R5,
+	register.R6,
+	register.R7,
+	register.R8,
+	register.Ac,
+	register.Bnk,
+	register.Sp,
+	register.Fp,
+	register.Ip,
+}
+
+// ContextHeader is number of words in context block before stack contents:
+// register file, then Ptb and stack frame counter.
+// Stack words follow, from Sp down, Sp/2 of them.
+var ContextHeader = len(ContextRegisters) + 2
+
+// ContextSize is the largest context block size in bytes, with full stack
+func (cpu Cpu) ContextSize() int {
+	return ContextHeader*2 + cpu.stackLimit
+}
+
+// ContextStackWords tells how many stack words follow the context header
+func ContextStackWords(header []uint16) int {
+	for idx, r := range ContextRegisters {
+		if r == register.Sp && idx < len(header) {
+			return int(header[idx]) / 2
+		}
+	}
+	return 0
+}
+
+// SaveContext returns register file values, page table base and the stack.
+// Inside of a trap handler, this is the context of interrupted code, the one SYSRET resumes.
+func (cpu Cpu) SaveContext() []uint16 {
+	values := make([]uint16, len(ContextRegisters), ContextHeader+int(cpu.sp)/2)
+	for idx, r := range ContextRegisters {
+		values[idx] = cpu.GetRegister(r)
+	}
+	if cpu.trap.active {
+		for idx, r := range ContextRegisters {
+			switch r {
+			case register.R1:
+				values[idx] = cpu.trap.r1
+			case register.R2:
+				values[idx] = cpu.trap.r2
+			case register.Ip:
+				values[idx] = cpu.trap.ip
+			}
+		}
+	}
+	values = append(values, cpu.ptb, uint16(cpu.stackSize))
+	for at := cpu.sp; at >= 2; at -= 2 {
+		value, _ := cpu.stack.GetUint16(memory.Address(at))
+		values = append(values, value)
+	}
+	return values
+}
+
+// LoadContext sets register file values. Inside of a trap handler,
+// Ip is left to the handler and the loaded context is resumed by SYSRET.
+func (cpu *Cpu) LoadContext(values []uint16) error {
+	if len(values) >= ContextHeader && ContextStackWords(values)*2 >= cpu.stackLimit {
+		return internal.Error(fmt.Sprintf("context stack does not fit: %d words", ContextStackWords(values)), nil, internal.ErrorContext)
+	}
+	for idx, r := range ContextRegisters {
+		if idx >= len(values) {
+			break
+		}
+		if cpu.trap.active {
+			switch r {
+			case register.R1:
+				cpu.trap.r1 = values[idx]
+			case register.R2:
+				cpu.trap.r2 = values[idx]
+			case register.Ip:
+				cpu.trap.ip = values[idx]
+				continue
+			}
+		}
+		cpu.SetRegister(r, values[idx])
+	}
+	if len(values) < ContextHeader {
+		return nil
+	}
+	cpu.ptb = values[len(ContextRegisters)]
+	cpu.stackSize = int(values[len(ContextRegisters)+1])
+	at := cpu.sp
+	for _, value := range values[ContextHeader:] {
+		if at < 2 {
+			break
+		}
+		if err := cpu.stack.SetUint16(memory.Address(at), value); err != nil {
+			return internal.Error("unable to load context stack", err, internal.ErrorContext)
+		}
+		at -= 2
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package cpu
+
+import (
+	"testing"
+	"the-machine/machine/register"
+)
+
+func Test_Context_SaveLoad(t *testing.T) {
+	cpu := NewCpu()
+	for idx, r := range ContextRegisters {
+		cpu.SetRegister(r, uint16(idx+1))
+	}
+	saved := cpu.SaveContext()
+
+	cpu.Reset()
+	cpu.LoadContext(saved)
+	for idx, r := range ContextRegisters {
+		if cpu.GetRegister(r) != uint16(idx+1) {
+			t.Fatalf("expected register %s to be restored to %d, got %d", r.Name(), idx+1, cpu.GetRegister(r))
+		}
+	}
+}
+
+func Test_Context_InTrap(t *testing.T) {
+	cpu := NewCpu()
+	cpu.SetRegister(register.Ip, 161)
+	cpu.SetRegister(register.R1, 13)
+	cpu.EnterTrap(TrapTimer, 0, 1312)
+
+	saved := cpu.SaveContext()
+	ip := len(ContextRegisters) - 1
+	if saved[0] != 13 || saved[ip] != 161 {
+		t.Fatalf("expected interrupted context to be saved, got %v", saved)
+	}
+
+	saved[0] = 12
+	saved[ip] = 255
+	cpu.LoadContext(saved)
+	if cpu.GetRegister(register.Ip) != 1312 {
+		t.Fatalf("expected handler to keep running, got Ip %d", cpu.GetRegister(register.Ip))
+	}
+
+	cpu.ReturnFromTrap()
+	if cpu.GetRegister(register.Ip) != 255 || cpu.GetRegister(register.R1) != 12 {
+		t.Fatalf("expected loaded context to be resumed, got Ip %d and R1 %d",
+			cpu.GetRegister(register.Ip), cpu.GetRegister(register.R1))
+	}
+}
+
+func Test_Context_OpenFrames(t *testing.T) {
+	cpu := NewCpu()
+	task := func(id uint16) []uint16 {
+		cpu.LoadContext(make([]uint16, ContextHeader))
+		cpu.SetRegister(register.Ptb, id*0x100)
+		cpu.Push(id * 10)
+		cpu.SetRegister(register.R1, id)
+		cpu.SetRegister(register.Ip, id*100)
+		if err := cpu.StoreFrame(); err != nil {
+			t.Fatalf("unable to call in task %d: %v", id, err)
+		}
+		cpu.Push(id * 11)
+		cpu.SetRegister(register.R1, 0)
+		return cpu.SaveContext()
+	}
+	a := task(1)
+	b := task(2)
+
+	for _, check := range []struct {
+		id  uint16
+		ctx []uint16
+	}{{1, a}, {2, b}, {1, a}} {
+		if err := cpu.LoadContext(check.ctx); err != nil {
+			t.Fatalf("unable to load task %d: %v", check.id, err)
+		}
+		if cpu.GetRegister(register.Ptb) != check.id*0x100 {
+			t.Fatalf("expected task %d page table, got %d", check.id, cpu.GetRegister(register.Ptb))
+		}
+		if err := cpu.RestoreFrame(); err != nil {
+			t.Fatalf("unable to return in task %d: %v", check.id, err)
+		}
+		if cpu.GetRegister(register.Ip) != check.id*100 || cpu.GetRegister(register.R1) != check.id {
+			t.Fatalf("expected task %d frame, got Ip %d and R1 %d", check.id, cpu.GetRegister(register.Ip), cpu.GetRegister(register.R1))
+		}
+		if cpu.GetRegister(register.Sp) != 0 {
+			t.Fatalf("expected task %d arguments to be dropped on return, got Sp %d", check.id, cpu.GetRegister(register.Sp))
+		}
+	}
+
+	big := make([]uint16, ContextHeader)
+	for idx, r := range ContextRegisters {
+		if r == register.Sp {
+			big[idx] = stackSize + 1
+		}
+	}
+	if err := cpu.LoadContext(big); err == nil {
+		t.Fatalf("expected error loading context with stack too large")
+	}
+}
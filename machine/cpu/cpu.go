@@ -9,15 +9,29 @@ import (
 
 const stackSize = 255
 
+// Controller is implemented by machines able to run more than one core
+type Controller interface {
+	StartCore(id uint16, at uint16) error
+	IsCoreRunning(id uint16) (bool, error)
+}
+
 type Cpu struct {
-	ip        uint16
-	sp        uint16
-	fp        uint16
-	ac        uint16
-	bnk       uint16
-	registers map[register.Register]uint16
-	stack     *memory.Memory
-	stackSize int
+	id         uint16
+	controller Controller
+	ip         uint16
+	sp         uint16
+	fp         uint16
+	ac         uint16
+	bnk        uint16
+	ptb        uint16
+	cycles     uint64
+	mode       Mode
+	trap       trapFrame
+	registers  map[register.Register]uint16
+	stack      *memory.Memory
+	stackSize  int
+	stackLimit int
+	tlbStale   bool
 }
 
 func NewCpu() *Cpu {
@@ -31,7 +45,35 @@ func NewCpu() *Cpu {
 	registers[register.R7] = 0
 	registers[register.R8] = 0
 	mem := memory.NewMemory(stackSize).(*memory.Memory)
-	return &Cpu{registers: registers, stack: mem}
+	return &Cpu{registers: registers, stack: mem, stackLimit: stackSize}
+}
+
+// SetStackSize replaces stack with an empty one of given size
+func (cpu *Cpu) SetStackSize(size int) error {
+	if size < 4 || size > 0xffff {
+		return internal.Error(fmt.Sprintf("invalid stack size: %d", size), nil, internal.ErrorCpu)
+	}
+	// One extra byte, so that uint16 at the last slot fits
+	cpu.stack = memory.NewMemory(size + 1).(*memory.Memory)
+	cpu.stackLimit = size
+	cpu.stackSize = 0
+	cpu.sp = 0
+	cpu.fp = 0
+	return nil
+}
+
+func NewCore(id uint16, controller Controller) *Cpu {
+	cpu := NewCpu()
+	cpu.id = id
+	cpu.controller = controller
+	return cpu
+}
+
+func (cpu Cpu) GetController() (Controller, error) {
+	if cpu.controller == nil {
+		return nil, internal.Error(fmt.Sprintf("core %d has no multi-core controller", cpu.id), nil, internal.ErrorCpu)
+	}
+	return cpu.controller, nil
 }
 
 func (cpu *Cpu) Reset() {
@@ -40,6 +82,11 @@ func (cpu *Cpu) Reset() {
 	cpu.fp = 0
 	cpu.ac = 0
 	cpu.bnk = 0
+	cpu.ptb = 0
+	cpu.cycles = 0
+	cpu.mode = Supervisor
+	cpu.trap = trapFrame{}
+	cpu.tlbStale = false
 	cpu.registers[register.R1] = 0
 	cpu.registers[register.R2] = 0
 	cpu.registers[register.R3] = 0
@@ -62,6 +109,12 @@ func (cpu Cpu) GetRegister(r register.Register) uint16 {
 		return cpu.ac
 	case register.Bnk:
 		return cpu.bnk
+	case register.Cid:
+		return cpu.id
+	case register.Cyc:
+		return uint16(cpu.cycles)
+	case register.Ptb:
+		return cpu.ptb
 	default:
 		if reg, ok := cpu.registers[r]; ok {
 			return reg
@@ -82,15 +135,41 @@ func (cpu *Cpu) SetRegister(r register.Register, v uint16) {
 		cpu.ac = v
 	case register.Bnk:
 		cpu.bnk = v
+	case register.Ptb:
+		cpu.ptb = v
+	case register.Cid, register.Cyc:
+		// Core ID and cycle counter are read-only
 	default:
 		cpu.registers[r] = v
 	}
 }
 
+func (cpu *Cpu) AddCycles(cycles uint64) {
+	cpu.cycles += cycles
+}
+
+// InvalidateTranslations marks cached address translations as stale,
+// to be dropped before the next translated access
+func (cpu *Cpu) InvalidateTranslations() {
+	cpu.tlbStale = true
+}
+
+// TranslationsInvalidated reports and clears pending invalidation
+func (cpu *Cpu) TranslationsInvalidated() bool {
+	stale := cpu.tlbStale
+	cpu.tlbStale = false
+	return stale
+}
+
+// GetCycles returns full cycle count, Cyc register holds only low 16 bits
+func (cpu Cpu) GetCycles() uint64 {
+	return cpu.cycles
+}
+
 func (cpu *Cpu) Push(value uint16) error {
 	address := cpu.GetRegister(register.Sp)
 	address += 2
-	if address >= stackSize {
+	if int(address) >= cpu.stackLimit {
 		return internal.Error(fmt.Sprintf("stack overflow, unable to push %d (%#02x) to %d (%#02x)", value, value, address, address), nil, internal.ErrorCpu)
 	}
 
@@ -198,3 +277,23 @@ func (cpu *Cpu) RestoreFrame() error {
 func (x Cpu) GetStack() (int, memory.MemoryAccess) {
 	return x.stackSize, x.stack
 }
+
+// Snapshot returns a copy of cpu state, registers and stack included
+func (cpu Cpu) Snapshot() *Cpu {
+	registers := make(map[register.Register]uint16, len(cpu.registers))
+	for r, v := range cpu.registers {
+		registers[r] = v
+	}
+	stack := append(memory.Memory{}, *cpu.stack...)
+	cpu.registers = registers
+	cpu.stack = &stack
+	return &cpu
+}
+
+// Restore puts cpu back into snapshot state, keeping its core identity
+func (cpu *Cpu) Restore(from *Cpu) {
+	id, controller := cpu.id, cpu.controller
+	*cpu = *from.Snapshot()
+	cpu.id = id
+	cpu.controller = controller
+}
@@ -0,0 +1,155 @@
+package cpu
+
+import (
+	"fmt"
+	"the-machine/machine/internal"
+	"the-machine/machine/register"
+)
+
+type Mode uint8
+
+const (
+	Supervisor Mode = 0
+	User       Mode = iota
+)
+
+func (x Mode) String() string {
+	switch x {
+	case Supervisor:
+		return "Supervisor"
+	case User:
+		return "User"
+	default:
+		return fmt.Sprintf("unknown mode: %d", x)
+	}
+}
+
+type Trap uint16
+
+const (
+	TrapNone       Trap = 0
+	TrapSyscall    Trap = iota
+	TrapPrivilege  Trap = iota
+	TrapProtection Trap = iota
+	TrapPageFault  Trap = iota
+	TrapTimer      Trap = iota
+	TrapIrq        Trap = iota
+)
+
+func (x Trap) String() string {
+	switch x {
+	case TrapSyscall:
+		return "Syscall"
+	case TrapPrivilege:
+		return "Privilege"
+	case TrapProtection:
+		return "Protection"
+	case TrapPageFault:
+		return "Page fault"
+	case TrapTimer:
+		return "Timer"
+	case TrapIrq:
+		return "IRQ"
+	default:
+		return fmt.Sprintf("unknown trap: %d", x)
+	}
+}
+
+// IsFault tells traps raised by a failed access. Their handlers return
+// to retry the faulting instruction, rather than the one after it.
+func (x Trap) IsFault() bool {
+	return x == TrapPrivilege || x == TrapProtection || x == TrapPageFault
+}
+
+// TrapRequest is raised as an error by anything that wants to hand
+// control over to the trap handler. Machine stops on it if there's
+// no handler set up for the trap.
+type TrapRequest struct {
+	Trap     Trap
+	Argument uint16
+	reason   string
+}
+
+func RaiseTrap(trap Trap, argument uint16, reason string) error {
+	return TrapRequest{Trap: trap, Argument: argument, reason: reason}
+}
+
+func (x TrapRequest) Error() string {
+	return fmt.Sprintf("[%s trap] %s (%d)", x.Trap, x.reason, x.Argument)
+}
+
+// State clobbered by entering a trap
+type trapFrame struct {
+	active bool
+	mode   Mode
+	ip     uint16
+	r1     uint16
+	r2     uint16
+}
+
+func (cpu Cpu) GetMode() Mode {
+	return cpu.mode
+}
+
+func (cpu *Cpu) SetMode(mode Mode) {
+	cpu.mode = mode
+}
+
+func (cpu Cpu) InTrap() bool {
+	return cpu.trap.active
+}
+
+// Privileged registers can only be written directly in supervisor mode
+func isPrivileged(r register.Register) bool {
+	return r == register.Ip || r == register.Sp || r == register.Fp || r == register.Bnk || r == register.Ptb
+}
+
+// CheckRegisterWrite verifies guest code can write to register in current mode
+func (cpu Cpu) CheckRegisterWrite(r register.Register) error {
+	if cpu.mode == User && isPrivileged(r) {
+		return RaiseTrap(TrapPrivilege, r.AsUint16(), fmt.Sprintf("register %s is supervisor-only", r.Name()))
+	}
+	return nil
+}
+
+// WriteRegister is register write on behalf of guest code, checked against current mode
+func (cpu *Cpu) WriteRegister(r register.Register, v uint16) error {
+	if err := cpu.CheckRegisterWrite(r); err != nil {
+		return err
+	}
+	cpu.SetRegister(r, v)
+	return nil
+}
+
+// EnterTrap switches to supervisor mode and jumps to handler, with trap
+// kind in R1 and trap argument in R2. Previous mode, Ip, R1 and R2 are
+// restored by ReturnFromTrap.
+func (cpu *Cpu) EnterTrap(trap Trap, argument uint16, handler uint16) error {
+	if cpu.trap.active {
+		return internal.Error(fmt.Sprintf("double fault: %s trap (%d) while handling trap", trap, argument), nil, internal.ErrorCpu)
+	}
+	cpu.trap = trapFrame{
+		active: true,
+		mode:   cpu.mode,
+		ip:     cpu.ip,
+		r1:     cpu.GetRegister(register.R1),
+		r2:     cpu.GetRegister(register.R2),
+	}
+	cpu.mode = Supervisor
+	cpu.SetRegister(register.R1, uint16(trap))
+	cpu.SetRegister(register.R2, argument)
+	cpu.ip = handler
+	return nil
+}
+
+func (cpu *Cpu) ReturnFromTrap() error {
+	if !cpu.trap.active {
+		return internal.Error("return from trap outside of trap handler", nil, internal.ErrorCpu)
+	}
+	cpu.mode = cpu.trap.mode
+	cpu.ip = cpu.trap.ip
+	cpu.SetRegister(register.R1, cpu.trap.r1)
+	cpu.SetRegister(register.R2, cpu.trap.r2)
+	cpu.trap = trapFrame{}
+	return nil
+}
@@ -0,0 +1,62 @@
+package cpu
+
+import (
+	"testing"
+	"the-machine/machine/register"
+)
+
+func Test_EnterTrap(t *testing.T) {
+	cpu := NewCpu()
+	cpu.SetMode(User)
+	cpu.SetRegister(register.Ip, 161)
+	cpu.SetRegister(register.R1, 13)
+	cpu.SetRegister(register.R2, 12)
+
+	if err := cpu.EnterTrap(TrapSyscall, 7, 1312); err != nil {
+		t.Fatalf("error entering trap: %v", err)
+	}
+	if cpu.GetMode() != Supervisor {
+		t.Fatalf("expected supervisor mode in trap")
+	}
+	if cpu.GetRegister(register.Ip) != 1312 {
+		t.Fatalf("expected jump to handler, got %d", cpu.GetRegister(register.Ip))
+	}
+	if cpu.GetRegister(register.R1) != uint16(TrapSyscall) || cpu.GetRegister(register.R2) != 7 {
+		t.Fatalf("expected trap kind and argument in R1 and R2")
+	}
+
+	if err := cpu.EnterTrap(TrapProtection, 0, 1312); err == nil {
+		t.Fatalf("expected double fault error")
+	}
+
+	if err := cpu.ReturnFromTrap(); err != nil {
+		t.Fatalf("error returning from trap: %v", err)
+	}
+	if cpu.GetMode() != User {
+		t.Fatalf("expected mode to be restored")
+	}
+	if cpu.GetRegister(register.Ip) != 161 || cpu.GetRegister(register.R1) != 13 || cpu.GetRegister(register.R2) != 12 {
+		t.Fatalf("expected registers to be restored")
+	}
+
+	if err := cpu.ReturnFromTrap(); err == nil {
+		t.Fatalf("expected error returning from trap outside of handler")
+	}
+}
+
+func Test_WriteRegister_Privileged(t *testing.T) {
+	cpu := NewCpu()
+	if err := cpu.WriteRegister(register.Bnk, 3); err != nil {
+		t.Fatalf("expected supervisor to write to Bnk, got %v", err)
+	}
+
+	cpu.SetMode(User)
+	for _, r := range []register.Register{register.Ip, register.Sp, register.Fp, register.Bnk, register.Ptb} {
+		if err := cpu.WriteRegister(r, 1); err == nil {
+			t.Fatalf("expected user mode write to %s to fail", r.Name())
+		}
+	}
+	if err := cpu.WriteRegister(register.R1, 1); err != nil {
+		t.Fatalf("expected user mode write to R1 to succeed, got %v", err)
+	}
+}
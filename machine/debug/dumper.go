@@ -24,6 +24,10 @@ func NewDumper() Dumpable {
 	return Dumper{fname: "out.bin"}
 }
 
+func NewBinaryLoader(fname string) Dumpable {
+	return Dumper{fname: fname}
+}
+
 func (x Dumper) Dump(mem memory.MemoryAccess) error {
 	return dumpRawMemory(x, mem, x.fname)
 }
@@ -0,0 +1,126 @@
+package debug
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+)
+
+type AccessCount struct {
+	Reads    uint64
+	Writes   uint64
+	Executes uint64
+}
+
+func (x AccessCount) Total() uint64 {
+	return x.Reads + x.Writes + x.Executes
+}
+
+// Heatmap counts accesses per address, per memory bank.
+// Uint16 accesses count against both bytes.
+type Heatmap struct {
+	counts map[memory.MemoryType]map[memory.Address]*AccessCount
+	lock   sync.Mutex
+}
+
+func NewHeatmap() *Heatmap {
+	return &Heatmap{counts: map[memory.MemoryType]map[memory.Address]*AccessCount{}}
+}
+
+func (x *Heatmap) Observe(access memory.Access) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	size := 1
+	if access.Operation == memory.ReadUint16 || access.Operation == memory.WriteUint16 || access.Operation == memory.Fetch {
+		size = 2
+	}
+	bank, ok := x.counts[access.Bank]
+	if !ok {
+		bank = map[memory.Address]*AccessCount{}
+		x.counts[access.Bank] = bank
+	}
+	for i := 0; i < size; i++ {
+		at := access.At + memory.Address(i)
+		count, ok := bank[at]
+		if !ok {
+			count = &AccessCount{}
+			bank[at] = count
+		}
+		switch {
+		case access.Operation == memory.Fetch:
+			count.Executes++
+		case access.Operation.IsWrite():
+			count.Writes++
+		default:
+			count.Reads++
+		}
+	}
+}
+
+// Count returns accesses to address in bank
+func (x *Heatmap) Count(bank memory.MemoryType, at memory.Address) AccessCount {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if count, ok := x.counts[bank][at]; ok {
+		return *count
+	}
+	return AccessCount{}
+}
+
+// Banks lists banks with recorded accesses
+func (x *Heatmap) Banks() []memory.MemoryType {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	banks := make([]memory.MemoryType, 0, len(x.counts))
+	for bank := range x.counts {
+		banks = append(banks, bank)
+	}
+	sort.Slice(banks, func(i, j int) bool { return banks[i] < banks[j] })
+	return banks
+}
+
+// Addresses lists accessed addresses in bank, in order
+func (x *Heatmap) Addresses(bank memory.MemoryType) []memory.Address {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	addresses := make([]memory.Address, 0, len(x.counts[bank]))
+	for at := range x.counts[bank] {
+		addresses = append(addresses, at)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	return addresses
+}
+
+// WriteCSV exports all counters, one address per row
+func (x *Heatmap) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"bank", "address", "reads", "writes", "executes"}); err != nil {
+		return internal.Error("unable to write heatmap header", err, internal.ErrorSaving)
+	}
+	for _, bank := range x.Banks() {
+		for _, at := range x.Addresses(bank) {
+			count := x.Count(bank, at)
+			row := []string{
+				bank.String(),
+				strconv.Itoa(int(at)),
+				strconv.FormatUint(count.Reads, 10),
+				strconv.FormatUint(count.Writes, 10),
+				strconv.FormatUint(count.Executes, 10),
+			}
+			if err := out.Write(row); err != nil {
+				return internal.Error(fmt.Sprintf("unable to write heatmap row for %s %d", bank, at), err, internal.ErrorSaving)
+			}
+		}
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return internal.Error("unable to write heatmap", err, internal.ErrorSaving)
+	}
+	return nil
+}
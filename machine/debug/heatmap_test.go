@@ -0,0 +1,41 @@
+package debug
+
+import (
+	"strings"
+	"testing"
+	"the-machine/machine/memory"
+)
+
+func Test_Heatmap(t *testing.T) {
+	heatmap := NewHeatmap()
+	heatmap.Observe(memory.Access{Bank: memory.ROM, Operation: memory.Fetch, At: 0})
+	heatmap.Observe(memory.Access{Bank: memory.ROM, Operation: memory.Fetch, At: 0})
+	heatmap.Observe(memory.Access{Bank: memory.RAM, Operation: memory.WriteByte, At: 13})
+	heatmap.Observe(memory.Access{Bank: memory.RAM, Operation: memory.ReadUint16, At: 12})
+
+	if count := heatmap.Count(memory.ROM, 1); count.Executes != 2 {
+		t.Fatalf("expected fetch to count against both bytes, got %v", count)
+	}
+	if count := heatmap.Count(memory.RAM, 13); count.Reads != 1 || count.Writes != 1 {
+		t.Fatalf("expected read and write counted, got %v", count)
+	}
+
+	var out strings.Builder
+	if err := heatmap.WriteCSV(&out); err != nil {
+		t.Fatalf("unable to export CSV: %v", err)
+	}
+	expected := "bank,address,reads,writes,executes\n" +
+		"RAM,12,1,0,0\n" +
+		"RAM,13,1,1,0\n" +
+		"ROM,0,0,0,2\n" +
+		"ROM,1,0,0,2\n"
+	if out.String() != expected {
+		t.Fatalf("unexpected CSV export:\n%s", out.String())
+	}
+
+	renderer := NewRenderer(Formatter{Numbers: Decimal, OutputAs: Uint})
+	rendered := renderer.Heatmap(heatmap, memory.RAM, 8)
+	if rendered != "    8 |    +@  |\n" {
+		t.Fatalf("unexpected heatmap: %q", rendered)
+	}
+}
@@ -15,12 +15,17 @@ const (
 	Inspect     Action = iota
 	PeekRam     Action = iota
 	PeekRom     Action = iota
+	PeekBus     Action = iota
 	Registers   Action = iota
 	Disassemble Action = iota
 	Stack       Action = iota
+	CacheStats  Action = iota
+	Screen      Action = iota
 	Dump        Action = iota
 	Load        Action = iota
 	Reset       Action = iota
+	Snapshot    Action = iota
+	Restore     Action = iota
 	Quit        Action = iota
 )
 
@@ -62,8 +67,8 @@ func NewInterface() *Interface {
 	return &Interface{}
 }
 
-func (x Interface) Prompt(ticks int, ip uint16) {
-	fmt.Printf("[tick: %d|ip: %d] > ", ticks, ip)
+func (x Interface) Prompt(ticks int, cycles uint64, ip uint16) {
+	fmt.Printf("[tick: %d|cycles: %d|ip: %d] > ", ticks, cycles, ip)
 }
 
 func (x Interface) GetCommand() (Actionable, error) {
@@ -91,8 +96,20 @@ func (x Interface) parseCommand(input string) (Actionable, error) {
 			return NewPeekCommand(PeekRom, input[1:]), nil
 		}
 		return Command{Action: PeekRom}, nil
+	case "b":
+		if len(input) > 1 {
+			return NewPeekCommand(PeekBus, input[1:]), nil
+		}
+		return Command{Action: PeekBus}, nil
 	case "s":
+		if len(input) > 3 && input[:4] == "snap" {
+			return Command{Action: Snapshot}, nil
+		}
 		return Command{Action: Stack}, nil
+	case "c":
+		return Command{Action: CacheStats}, nil
+	case "v":
+		return Command{Action: Screen}, nil
 	case "d":
 		if len(input) > 3 && input[:4] == "dump" {
 			return Command{Action: Dump}, nil
@@ -104,6 +121,8 @@ func (x Interface) parseCommand(input string) (Actionable, error) {
 	case "r":
 		if len(input) > 4 && input[:5] == "reset" {
 			return Command{Action: Reset}, nil
+		} else if len(input) > 6 && input[:7] == "restore" {
+			return Command{Action: Restore}, nil
 		} else {
 			return Command{Action: Registers}, nil
 		}
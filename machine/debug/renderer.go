@@ -51,6 +51,15 @@ func (x Renderer) Memory(source memory.MemoryAccess, startAt memory.Address, out
 		positions[i], values[i] = x.memoryAt(source, memory.Address(pos))
 	}
 
+	if labeler, ok := source.(memory.Labeler); ok {
+		labels := make([]string, outputLen, outputLen)
+		for i := 0; i < outputLen; i++ {
+			format := fmt.Sprintf("%%%ds", len(values[i]))
+			labels[i] = fmt.Sprintf(format, labeler.Label(memory.Address(int(startAt)+i)))
+		}
+		return x.formatter.Stitch(positions, values, labels)
+	}
+
 	return x.formatter.Stitch(positions, values)
 }
 
@@ -164,3 +173,39 @@ func (x Renderer) OutError(area string, src error) {
 		err = errors.Unwrap(err)
 	}
 }
+
+// Heat shades, from cold to hot
+const heatShades = " .:-=+*#%@"
+
+// Heatmap renders accessed range of bank, width addresses per line
+func (x Renderer) Heatmap(heatmap *Heatmap, bank memory.MemoryType, width int) string {
+	addresses := heatmap.Addresses(bank)
+	if len(addresses) == 0 || width <= 0 {
+		return ""
+	}
+	var hottest uint64
+	for _, at := range addresses {
+		if total := heatmap.Count(bank, at).Total(); total > hottest {
+			hottest = total
+		}
+	}
+
+	posFormat, _ := x.formatter.GetFormat()
+	from := int(addresses[0]) / width * width
+	to := int(addresses[len(addresses)-1])
+	var out strings.Builder
+	for line := from; line <= to; line += width {
+		out.WriteString(fmt.Sprintf(posFormat, line))
+		out.WriteString(" |")
+		for at := line; at < line+width; at++ {
+			total := heatmap.Count(bank, memory.Address(at)).Total()
+			shade := 0
+			if total > 0 {
+				shade = 1 + int(total*uint64(len(heatShades)-2)/hottest)
+			}
+			out.WriteByte(heatShades[shade])
+		}
+		out.WriteString("|\n")
+	}
+	return out.String()
+}
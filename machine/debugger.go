@@ -3,6 +3,7 @@ package machine
 import (
 	"fmt"
 	"the-machine/machine/debug"
+	"the-machine/machine/device"
 	"the-machine/machine/internal"
 	"the-machine/machine/memory"
 	"the-machine/machine/register"
@@ -35,6 +36,37 @@ func (x Debugger) ramAt(memPos memory.Address, length int) {
 	x.renderer.Out(x.Peek(memPos, length, memory.RAM))
 }
 
+func (x Debugger) busAt(memPos memory.Address, length int) {
+	x.renderer.Out("[ Bus ]")
+	x.renderer.Out(x.Peek(memPos, length, memory.Flat))
+}
+
+func (x Debugger) screen() {
+	x.renderer.Out("[ Screen ]")
+	vga, err := x.vm.getMemory(memory.DeviceVGA)
+	if err != nil {
+		x.renderer.OutError("debugger error", internal.Error("unable to access screen", err, internal.ErrorDebugger))
+		return
+	}
+	video, ok := memory.Unwrap(vga).(device.Video)
+	if !ok {
+		x.renderer.OutError("debugger error", internal.Error("screen is not readable", nil, internal.ErrorDebugger))
+		return
+	}
+	for _, line := range video.Lines() {
+		x.renderer.Out("|" + line)
+	}
+}
+
+func (x Debugger) cacheStats() {
+	x.renderer.Out("[ Cache ]")
+	if cache := x.vm.GetCache(); cache != nil {
+		x.renderer.Out(cache.Stats().String())
+	} else {
+		x.renderer.Out("disabled")
+	}
+}
+
 func (x Debugger) currentRom() {
 	memPos := x.vm.cpu.GetRegister(register.Ip)
 	x.romAt(memory.Address(memPos), 8)
@@ -75,6 +107,8 @@ func (x Debugger) currentRegisters() {
 func (x Debugger) Run() {
 	doTick := false
 	ticks := 0
+	var snapshot *Snapshot
+	snapshotTicks := 0
 	for true {
 		if doTick {
 			err := x.vm.Tick()
@@ -88,7 +122,7 @@ func (x Debugger) Run() {
 			}
 		}
 		doTick = true
-		x.skin.Prompt(ticks, x.vm.cpu.GetRegister(register.Ip))
+		x.skin.Prompt(ticks, x.vm.GetCycles(), x.vm.cpu.GetRegister(register.Ip))
 		cmd, err := x.skin.GetCommand()
 		if err != nil {
 			x.renderer.OutError("debugger error", err)
@@ -121,6 +155,22 @@ func (x Debugger) Run() {
 			}
 			doTick = false
 			continue
+		case debug.PeekBus:
+			if peek, ok := cmd.(debug.PeekCommand); ok {
+				x.busAt(peek.At, peek.Length)
+			} else {
+				x.busAt(0, 8)
+			}
+			doTick = false
+			continue
+		case debug.Screen:
+			x.screen()
+			doTick = false
+			continue
+		case debug.CacheStats:
+			x.cacheStats()
+			doTick = false
+			continue
 		case debug.Stack:
 			x.currentStack()
 			doTick = false
@@ -157,6 +207,23 @@ func (x Debugger) Run() {
 			x.vm.Reset()
 			doTick = false
 			continue
+		case debug.Snapshot:
+			snapshot = x.vm.Snapshot()
+			snapshotTicks = ticks
+			x.renderer.Out(fmt.Sprintf("Took snapshot at tick %d", ticks))
+			doTick = false
+			continue
+		case debug.Restore:
+			if snapshot == nil {
+				x.renderer.OutError("debugger error", internal.Error("no snapshot to restore", nil, internal.ErrorDebugger))
+			} else if err := x.vm.Restore(snapshot); err != nil {
+				x.renderer.OutError("debugger error", err)
+			} else {
+				ticks = snapshotTicks
+				x.renderer.Out(fmt.Sprintf("Restored snapshot from tick %d", ticks))
+			}
+			doTick = false
+			continue
 		case debug.Quit:
 			break
 		}
@@ -175,6 +242,9 @@ func (x Debugger) CoreRegisters() string {
 		register.Sp,
 		register.Fp,
 		register.Bnk,
+		register.Cid,
+		register.Cyc,
+		register.Ptb,
 	})
 }
 
@@ -198,6 +268,9 @@ func (x Debugger) AllRegisters() string {
 		register.Sp,
 		register.Fp,
 		register.Bnk,
+		register.Cid,
+		register.Cyc,
+		register.Ptb,
 		register.R1,
 		register.R2,
 		register.R3,
@@ -224,6 +297,12 @@ func (x Debugger) getMemory(srcType memory.MemoryType) memory.MemoryAccess {
 		} else {
 			source = rom
 		}
+	case memory.Flat:
+		if bus, err := x.vm.getMemory(memory.Flat); err != nil {
+			x.renderer.OutError("debugger error", internal.Error("unable to access bus", err, internal.ErrorDebugger))
+		} else {
+			source = bus
+		}
 	default:
 		// x.renderer.OutError(fmt.Sprintf("ERROR: unknown source type: %v", srcType))
 		x.renderer.OutError("debugger error", internal.Error(fmt.Sprintf("unknown source type: %v", srcType), nil, internal.ErrorDebugger))
@@ -232,18 +311,18 @@ func (x Debugger) getMemory(srcType memory.MemoryType) memory.MemoryAccess {
 }
 
 func (x Debugger) Peek(startAt memory.Address, outputLen int, srcType memory.MemoryType) string {
-	source := x.getMemory(srcType)
+	source := memory.Unwrap(x.getMemory(srcType))
 	return x.renderer.Memory(source, startAt, outputLen)
 }
 
 func (x Debugger) Disassemble(startAt memory.Address, outputLen int) string {
-	source := x.getMemory(memory.ROM)
+	source := memory.Unwrap(x.getMemory(memory.ROM))
 	return x.renderer.Disassembly(source, startAt, outputLen)
 }
 
 func (x Debugger) Dump() error {
 	dumper := debug.NewAsciiDumper(debug.Decimal)
-	source := x.getMemory(memory.ROM)
+	source := memory.Unwrap(x.getMemory(memory.ROM))
 	return dumper.Dump(source)
 }
 
@@ -0,0 +1,203 @@
+package device
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+	"time"
+)
+
+// Device is memory-mapped hardware with a lifecycle
+type Device interface {
+	memory.MemoryAccess
+	// Attach is called once the device is mapped as memory type
+	Attach(kind memory.MemoryType) error
+	// Reset is called on machine reset
+	Reset()
+	// Tick is called after each instruction, with cycles it took
+	Tick(cycles uint64) error
+	// Detach is called once the device is unmapped, to release resources
+	Detach() error
+}
+
+// Interrupter is implemented by devices with an IRQ line
+type Interrupter interface {
+	PendingIrq() bool
+	AcknowledgeIrq()
+}
+
+// Passive adapts plain memory-mapped device to Device, with no-op hooks
+type Passive struct {
+	mem memory.MemoryAccess
+}
+
+func NewPassive(mem memory.MemoryAccess) Device {
+	return Passive{mem: mem}
+}
+
+func (x Passive) GetByte(at memory.Address) (byte, error)         { return x.mem.GetByte(at) }
+func (x Passive) GetUint16(at memory.Address) (uint16, error)     { return x.mem.GetUint16(at) }
+func (x Passive) SetByte(at memory.Address, value byte) error     { return x.mem.SetByte(at, value) }
+func (x Passive) SetUint16(at memory.Address, value uint16) error { return x.mem.SetUint16(at, value) }
+func (x Passive) Attach(memory.MemoryType) error                  { return nil }
+func (x Passive) Reset()                                          {}
+func (x Passive) Tick(uint64) error                               { return nil }
+func (x Passive) Detach() error                                   { return nil }
+func (x Passive) Unwrap() memory.MemoryAccess                     { return x.mem }
+
+// Params are device parameters, such as from machine config
+type Params map[string]string
+
+func (x Params) String(key string, fallback string) string {
+	if value, ok := x[key]; ok {
+		return value
+	}
+	return fallback
+}
+
+func (x Params) Int(key string, fallback int) (int, error) {
+	value, ok := x[key]
+	if !ok {
+		return fallback, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback, internal.Error(fmt.Sprintf("invalid %s parameter: %q", key, value), err, internal.ErrorDevice)
+	}
+	return i, nil
+}
+
+type Factory func(Params) (Device, error)
+
+type registration struct {
+	kind    memory.MemoryType
+	factory Factory
+}
+
+var registry = struct {
+	devices map[string]registration
+	lock    sync.Mutex
+}{devices: map[string]registration{}}
+
+// Register makes device kind available by name, mapped to memory type.
+// Both name and memory type have to be unique. Memory types below
+// memory.DeviceCustom, and their names, are reserved for built-ins.
+func Register(name string, kind memory.MemoryType, factory Factory) error {
+	if kind < memory.DeviceCustom {
+		return internal.Error(fmt.Sprintf("memory type %s is reserved, wanted by %s", kind, name), nil, internal.ErrorDevice)
+	}
+	for builtin := memory.MemoryType(0); builtin < memory.DeviceCustom; builtin++ {
+		if strings.EqualFold(name, builtin.String()) {
+			return internal.Error(fmt.Sprintf("device name %s is reserved", name), nil, internal.ErrorDevice)
+		}
+	}
+	return register(name, kind, factory)
+}
+
+func register(name string, kind memory.MemoryType, factory Factory) error {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if _, ok := registry.devices[name]; ok {
+		return internal.Error(fmt.Sprintf("device %s already registered", name), nil, internal.ErrorDevice)
+	}
+	for other, r := range registry.devices {
+		if r.kind == kind {
+			return internal.Error(fmt.Sprintf("device %s already mapped to %s, wanted by %s", other, kind, name), nil, internal.ErrorDevice)
+		}
+	}
+	registry.devices[name] = registration{kind: kind, factory: factory}
+	return nil
+}
+
+// Create builds registered device by name
+func Create(name string, params Params) (memory.MemoryType, Device, error) {
+	registry.lock.Lock()
+	r, ok := registry.devices[name]
+	registry.lock.Unlock()
+	if !ok {
+		return 0, nil, internal.Error(fmt.Sprintf("unknown device: %s", name), nil, internal.ErrorDevice)
+	}
+	dev, err := r.factory(params)
+	if err != nil {
+		return r.kind, dev, internal.Error(fmt.Sprintf("unable to create device %s", name), err, internal.ErrorDevice)
+	}
+	return r.kind, dev, nil
+}
+
+// Registered lists registered device names
+func Registered() []string {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	names := make([]string, 0, len(registry.devices))
+	for name := range registry.devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	register("vga", memory.DeviceVGA, newVideoDevice)
+	register("io", memory.DeviceIO, newIoDevice)
+	register("keyboard", memory.DeviceKeyboard, func(params Params) (Device, error) {
+		size, err := params.Int("buffer", defaultKeyboardBuffer)
+		if err != nil {
+			return nil, err
+		}
+		return NewTerminalKeyboard(size), nil
+	})
+	register("disk", memory.DeviceDisk, func(params Params) (Device, error) {
+		path := params.String("path", "")
+		if path == "" {
+			return nil, internal.Error("disk needs image path", nil, internal.ErrorDevice)
+		}
+		disk, err := OpenDisk(path)
+		if err != nil {
+			return nil, err
+		}
+		return disk, nil
+	})
+	register("net", memory.DeviceNet, newNetDevice)
+	register("timer", memory.DeviceTimer, func(params Params) (Device, error) {
+		switch source := params.String("clock", "wall"); source {
+		case "wall":
+			return NewTimer(WallClock{}), nil
+		case "virtual":
+			ns, err := params.Int("cycle_ns", 1000)
+			if err != nil {
+				return nil, err
+			}
+			return NewTimer(NewVirtualClock(time.Unix(0, 0).UTC(), time.Duration(ns))), nil
+		default:
+			return nil, internal.Error(fmt.Sprintf("unknown clock: %s", source), nil, internal.ErrorDevice)
+		}
+	})
+}
+
+func newVideoDevice(params Params) (Device, error) {
+	var video Video
+	switch presenter := params.String("presenter", "terminal"); presenter {
+	case "terminal":
+		video = NewVideo().(Video)
+	case "headless":
+		video = NewHeadlessVideo().(Video)
+	default:
+		return nil, internal.Error(fmt.Sprintf("unknown presenter: %s", presenter), nil, internal.ErrorDevice)
+	}
+	width, err := params.Int("width", video.graphics.width)
+	if err != nil {
+		return nil, err
+	}
+	height, err := params.Int("height", video.graphics.height)
+	if err != nil {
+		return nil, err
+	}
+	if err := video.SetResolution(width, height); err != nil {
+		return nil, err
+	}
+	return NewPassive(video), nil
+}
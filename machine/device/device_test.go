@@ -0,0 +1,48 @@
+package device
+
+import (
+	"testing"
+	"the-machine/machine/memory"
+)
+
+func Test_Registry(t *testing.T) {
+	if err := Register("vga", memory.DeviceCustom, nil); err == nil {
+		t.Fatalf("expected error registering duplicate name")
+	}
+	if err := Register("screen", memory.DeviceVGA, nil); err == nil {
+		t.Fatalf("expected error registering built-in memory type")
+	}
+	if err := Register("file", memory.DeviceCustom+1, nil); err == nil {
+		t.Fatalf("expected error registering built-in memory type name")
+	}
+	if err := Register("tape", memory.DeviceCustom+1, nil); err != nil {
+		t.Fatalf("unable to register custom device: %v", err)
+	}
+	if err := Register("punchcard", memory.DeviceCustom+1, nil); err == nil {
+		t.Fatalf("expected error registering duplicate memory type")
+	}
+	if _, _, err := Create("teleporter", nil); err == nil {
+		t.Fatalf("expected error creating unknown device")
+	}
+
+	kind, dev, err := Create("io", nil)
+	if err != nil || kind != memory.DeviceIO {
+		t.Fatalf("expected built-in io device, got %v and error %v", kind, err)
+	}
+	if _, ok := memory.Unwrap(dev).(*IOMap); !ok {
+		t.Fatalf("expected passive device to unwrap to io map")
+	}
+}
+
+func Test_Params(t *testing.T) {
+	params := Params{"size": "12", "bad": "x"}
+	if size, err := params.Int("size", 0); err != nil || size != 12 {
+		t.Fatalf("expected int param, got %d and error %v", size, err)
+	}
+	if _, err := params.Int("bad", 0); err == nil {
+		t.Fatalf("expected error parsing invalid int param")
+	}
+	if params.String("missing", "fallback") != "fallback" {
+		t.Fatalf("expected fallback for missing param")
+	}
+}
@@ -0,0 +1,216 @@
+package device
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+)
+
+const SectorSize = 256
+
+// Disk registers are uint16, sector buffer is a window of SectorSize bytes
+const (
+	DiskCommand memory.Address = 0x00 // Write starts a command
+	DiskSector  memory.Address = 0x02 // Sector for read and write commands
+	DiskStatus  memory.Address = 0x04 // Status flags
+	DiskError   memory.Address = 0x06 // Error code of the last command
+	DiskSectors memory.Address = 0x08 // Number of sectors on disk
+	DiskBuffer  memory.Address = 0x100
+)
+
+// Disk commands
+const (
+	DiskRead  uint16 = 1 // Load sector into buffer
+	DiskWrite uint16 = 2 // Store buffer to sector
+	DiskFlush uint16 = 3 // Sync image to host storage
+)
+
+// Disk status flags
+const (
+	DiskReady  uint16 = 1 << iota
+	DiskFailed uint16 = 1 << iota
+)
+
+// Disk error codes
+const (
+	DiskOk          uint16 = 0
+	DiskBadSector   uint16 = 1
+	DiskBadCommand  uint16 = 2
+	DiskIoError     uint16 = 3
+	DiskNotAttached uint16 = 4
+)
+
+// Disk is block storage backed by image file on host
+type Disk struct {
+	image   *os.File
+	sectors uint16
+	sector  uint16
+	status  uint16
+	errno   uint16
+	buffer  [SectorSize]byte
+}
+
+// CreateDiskImage creates blank image with given number of sectors
+func CreateDiskImage(path string, sectors int) error {
+	if sectors <= 0 || sectors > 0xffff {
+		return internal.Error(fmt.Sprintf("invalid number of sectors: %d", sectors), nil, internal.ErrorDevice)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return internal.Error(fmt.Sprintf("unable to create disk image %s", path), err, internal.ErrorDevice)
+	}
+	defer f.Close()
+	if err := f.Truncate(int64(sectors) * SectorSize); err != nil {
+		return internal.Error(fmt.Sprintf("unable to size disk image %s", path), err, internal.ErrorDevice)
+	}
+	return nil
+}
+
+// OpenDisk opens image, which has to be made of whole sectors
+func OpenDisk(path string) (*Disk, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, internal.Error(fmt.Sprintf("unable to open disk image %s", path), err, internal.ErrorDevice)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, internal.Error(fmt.Sprintf("unable to open disk image %s", path), err, internal.ErrorDevice)
+	}
+	if info.Size()%SectorSize != 0 || info.Size()/SectorSize > 0xffff {
+		f.Close()
+		return nil, internal.Error(fmt.Sprintf("invalid disk image %s size: %d", path, info.Size()), nil, internal.ErrorDevice)
+	}
+	return &Disk{image: f, sectors: uint16(info.Size() / SectorSize), status: DiskReady}, nil
+}
+
+func (x *Disk) run(command uint16) {
+	x.errno = DiskOk
+	switch {
+	case x.image == nil:
+		x.errno = DiskNotAttached
+	case command == DiskFlush:
+		if err := x.image.Sync(); err != nil {
+			x.errno = DiskIoError
+		}
+	case command != DiskRead && command != DiskWrite:
+		x.errno = DiskBadCommand
+	case x.sector >= x.sectors:
+		x.errno = DiskBadSector
+	case command == DiskRead:
+		if _, err := x.image.ReadAt(x.buffer[:], int64(x.sector)*SectorSize); err != nil {
+			x.errno = DiskIoError
+		}
+	case command == DiskWrite:
+		if _, err := x.image.WriteAt(x.buffer[:], int64(x.sector)*SectorSize); err != nil {
+			x.errno = DiskIoError
+		}
+	}
+	x.status = DiskReady
+	if x.errno != DiskOk {
+		x.status |= DiskFailed
+	}
+}
+
+func (x *Disk) get(reg memory.Address) (uint16, error) {
+	switch reg {
+	case DiskCommand:
+		return 0, nil
+	case DiskSector:
+		return x.sector, nil
+	case DiskStatus:
+		return x.status, nil
+	case DiskError:
+		return x.errno, nil
+	case DiskSectors:
+		return x.sectors, nil
+	}
+	return 0, internal.Error(fmt.Sprintf("unknown disk register %d", reg), nil, internal.ErrorDevice)
+}
+
+func (x *Disk) set(reg memory.Address, value uint16) error {
+	switch reg {
+	case DiskCommand:
+		x.run(value)
+		return nil
+	case DiskSector:
+		x.sector = value
+		return nil
+	}
+	return internal.Error(fmt.Sprintf("unable to write disk register %d", reg), nil, internal.ErrorDevice)
+}
+
+func inBuffer(at memory.Address, size int) bool {
+	return at >= DiskBuffer && int(at)+size <= int(DiskBuffer)+SectorSize
+}
+
+func (x *Disk) GetByte(at memory.Address) (byte, error) {
+	if inBuffer(at, 1) {
+		return x.buffer[at-DiskBuffer], nil
+	}
+	value, err := x.get(at &^ 1)
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, value)
+	return b[at&1], err
+}
+
+func (x *Disk) GetUint16(at memory.Address) (uint16, error) {
+	if inBuffer(at, 2) {
+		offset := at - DiskBuffer
+		return binary.LittleEndian.Uint16(x.buffer[offset : offset+2]), nil
+	}
+	return x.get(at)
+}
+
+// SetByte writes to sector buffer, or sets whole register
+func (x *Disk) SetByte(at memory.Address, value byte) error {
+	if inBuffer(at, 1) {
+		x.buffer[at-DiskBuffer] = value
+		return nil
+	}
+	return x.set(at, uint16(value))
+}
+
+func (x *Disk) SetUint16(at memory.Address, value uint16) error {
+	if inBuffer(at, 2) {
+		offset := at - DiskBuffer
+		binary.LittleEndian.PutUint16(x.buffer[offset:offset+2], value)
+		return nil
+	}
+	return x.set(at, value)
+}
+
+func (x *Disk) Attach(memory.MemoryType) error {
+	return nil
+}
+
+// Reset clears controller state, disk contents stay
+func (x *Disk) Reset() {
+	x.sector = 0
+	x.errno = DiskOk
+	x.status = DiskReady
+	x.buffer = [SectorSize]byte{}
+}
+
+func (x *Disk) Tick(uint64) error {
+	return nil
+}
+
+// Detach syncs and closes the image
+func (x *Disk) Detach() error {
+	if x.image == nil {
+		return nil
+	}
+	image := x.image
+	x.image = nil
+	if err := image.Sync(); err != nil {
+		image.Close()
+		return internal.Error("unable to sync disk image", err, internal.ErrorDevice)
+	}
+	if err := image.Close(); err != nil {
+		return internal.Error("unable to close disk image", err, internal.ErrorDevice)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package device
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_Disk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := CreateDiskImage(path, 4); err != nil {
+		t.Fatalf("unable to create disk image: %v", err)
+	}
+	if err := CreateDiskImage(path, 4); err == nil {
+		t.Fatalf("expected error overwriting existing image")
+	}
+
+	disk, err := OpenDisk(path)
+	if err != nil {
+		t.Fatalf("unable to open disk image: %v", err)
+	}
+	if sectors, _ := disk.GetUint16(DiskSectors); sectors != 4 {
+		t.Fatalf("expected 4 sectors, got %d", sectors)
+	}
+
+	disk.SetUint16(DiskBuffer, 1312)
+	disk.SetByte(DiskBuffer+SectorSize-1, 161)
+	disk.SetUint16(DiskSector, 2)
+	disk.SetUint16(DiskCommand, DiskWrite)
+	if status, _ := disk.GetUint16(DiskStatus); status != DiskReady {
+		t.Fatalf("expected successful write, got status %d", status)
+	}
+
+	disk.SetUint16(DiskSector, 4)
+	disk.SetUint16(DiskCommand, DiskRead)
+	if errno, _ := disk.GetUint16(DiskError); errno != DiskBadSector {
+		t.Fatalf("expected bad sector error, got %d", errno)
+	}
+	if status, _ := disk.GetUint16(DiskStatus); status&DiskFailed == 0 {
+		t.Fatalf("expected failed status, got %d", status)
+	}
+	if err := disk.SetUint16(DiskBuffer+SectorSize-1, 1); err == nil {
+		t.Fatalf("expected error writing past sector buffer")
+	}
+	disk.Detach()
+
+	disk, _ = OpenDisk(path)
+	defer disk.Detach()
+	disk.SetUint16(DiskSector, 2)
+	disk.SetUint16(DiskCommand, DiskRead)
+	if value, _ := disk.GetUint16(DiskBuffer); value != 1312 {
+		t.Fatalf("expected sector to persist, got %d", value)
+	}
+	if value, _ := disk.GetByte(DiskBuffer + SectorSize - 1); value != 161 {
+		t.Fatalf("expected sector to persist, got %d", value)
+	}
+}
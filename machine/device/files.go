@@ -0,0 +1,467 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+)
+
+// Control addresses for guest file access, past the descriptors.
+// Guest writes path bytes and mode, then issues open command and
+// gets descriptor back in result register. Descriptor is then
+// read and written as usual, and can be seeked and closed.
+const (
+	IoControl    memory.Address = 0x100
+	IoPath       memory.Address = 0x100 // Byte writes append to path, zero byte clears it
+	IoMode       memory.Address = 0x102 // AccessType for open, with IoAppend flag
+	IoCommand    memory.Address = 0x104 // Write runs command
+	IoDescriptor memory.Address = 0x106 // Descriptor for close and seek
+	IoOffset     memory.Address = 0x108 // Seek offset, signed when seeking from current position or end
+	IoWhence     memory.Address = 0x10a // Seek whence: 0 start, 1 current, 2 end
+	IoResult     memory.Address = 0x10c // Descriptor opened, or position seeked to (IoOverflow past 0xffff)
+	IoError      memory.Address = 0x10e // Error code of the last command
+	IoStatus     memory.Address = 0x110 // Status word of the descriptor
+	IoAvailable  memory.Address = 0x112 // Bytes left to read from the descriptor, if known
+	IoControlEnd memory.Address = 0x114
+)
+
+// Descriptor status word flags, with last error code in the high byte
+const (
+	FdOpen     uint16 = 1 << 0
+	FdReadable uint16 = 1 << 1
+	FdWritable uint16 = 1 << 2
+	FdEOF      uint16 = 1 << 3
+	FdError    uint16 = 1 << 4
+)
+
+// IoUnknownAvailable is reported when stream size can not be known, e.g. for pipes
+const IoUnknownAvailable uint16 = 0xffff
+
+// IoAppend mode flag appends writes instead of truncating
+const IoAppend AccessType = 1 << 2
+
+// IO commands
+const (
+	IoOpen  uint16 = 1
+	IoClose uint16 = 2
+	IoSeek  uint16 = 3
+)
+
+// IO error codes
+const (
+	IoOk             uint16 = 0
+	IoNotFound       uint16 = 1
+	IoPermission     uint16 = 2
+	IoOutsideSandbox uint16 = 3
+	IoBadDescriptor  uint16 = 4
+	IoNoDescriptors  uint16 = 5
+	IoBadCommand     uint16 = 6
+	IoFailed         uint16 = 7
+	IoOverflow       uint16 = 8
+)
+
+// firstGuestDescriptor leaves standard streams and a few more for host
+const firstGuestDescriptor FileDescriptor = 16
+
+type ioControl struct {
+	sandbox    string
+	opened     map[FileDescriptor]bool // Descriptors opened by the guest, the only ones it can close
+	path       []byte
+	mode       uint16
+	descriptor uint16
+	offset     uint16
+	whence     uint16
+	result     uint16
+	errno      uint16
+}
+
+// ioFailure carries error code for the guest
+type ioFailure struct {
+	code uint16
+	err  error
+}
+
+func (x ioFailure) Error() string {
+	return fmt.Sprintf("io error %d: %v", x.code, x.err)
+}
+
+func (x ioFailure) Unwrap() error {
+	return x.err
+}
+
+// isStreamError tells if error is to be reported to the guest through
+// descriptor status, rather than stopping the machine
+func isStreamError(err error) bool {
+	var failure ioFailure
+	return errors.Is(err, io.EOF) || errors.As(err, &failure)
+}
+
+// fileStatus is shared between copies of the same Filelike
+type fileStatus struct {
+	eof   bool
+	errno uint16
+}
+
+// record keeps outcome of the last stream operation
+func (x Filelike) record(err error) error {
+	if x.status == nil {
+		return err
+	}
+	x.status.eof = errors.Is(err, io.EOF)
+	if x.status.eof {
+		x.status.errno = IoOk
+		return err
+	}
+	x.status.errno = ErrorCode(err)
+	if err == nil {
+		return nil
+	}
+	return ioFailure{code: x.status.errno, err: err}
+}
+
+// Status packs descriptor flags and last error code into status word
+func (x Filelike) Status() uint16 {
+	status := FdOpen
+	if x.access&Read != 0 {
+		status |= FdReadable
+	}
+	if x.access&Write != 0 {
+		status |= FdWritable
+	}
+	if x.status != nil {
+		if x.status.eof {
+			status |= FdEOF
+		}
+		if x.status.errno != IoOk {
+			status |= FdError | x.status.errno<<8
+		}
+	}
+	return status
+}
+
+// Available tells how many bytes are left to read, if that can be known
+func (x Filelike) Available() (int, bool) {
+	if x.access&Read == 0 {
+		return 0, true
+	}
+	switch stream := x.stream.(type) {
+	case interface{ Len() int }:
+		return stream.Len(), true
+	case *os.File:
+		info, err := stream.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			break
+		}
+		pos, err := stream.Seek(0, io.SeekCurrent)
+		if err != nil {
+			break
+		}
+		return int(info.Size() - pos), true
+	}
+	if x.status != nil && x.status.eof {
+		return 0, true
+	}
+	return 0, false
+}
+
+// ErrorCode maps error to code reported to the guest
+func ErrorCode(err error) uint16 {
+	var failure ioFailure
+	switch {
+	case err == nil:
+		return IoOk
+	case errors.As(err, &failure):
+		return failure.code
+	case errors.Is(err, fs.ErrNotExist):
+		return IoNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return IoPermission
+	default:
+		return IoFailed
+	}
+}
+
+// OpenFile opens host file as descriptor
+func OpenFile(fd FileDescriptor, path string, access AccessType) (Filelike, error) {
+	var flags int
+	switch access &^ IoAppend {
+	case Read:
+		flags = os.O_RDONLY
+	case Write:
+		flags = os.O_WRONLY | os.O_CREATE
+	case Read | Write:
+		flags = os.O_RDWR | os.O_CREATE
+	default:
+		return Filelike{}, ioFailure{code: IoPermission, err: fmt.Errorf("invalid access mode %d", access)}
+	}
+	if access&Write != 0 {
+		if access&IoAppend != 0 {
+			flags |= os.O_APPEND
+		} else if access&Read == 0 {
+			flags |= os.O_TRUNC
+		}
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return Filelike{}, internal.Error(fmt.Sprintf("unable to open %s as %s", path, fd), err, internal.ErrorDevice)
+	}
+	return NewFilelike(fd, access&^IoAppend, f), nil
+}
+
+// Seek moves position in seekable stream
+func (x Filelike) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := x.stream.(io.Seeker)
+	if !ok {
+		return 0, internal.Error(fmt.Sprintf("not seekable: %v", x), nil, internal.ErrorDevice)
+	}
+	pos, err := seeker.Seek(offset, whence)
+	if err != nil {
+		return pos, x.record(internal.Error(fmt.Sprintf("seek error: %v", x), err, internal.ErrorDevice))
+	}
+	x.record(nil)
+	return pos, nil
+}
+
+// Close releases the stream, if it can be closed.
+// Process standard streams are left open.
+func (x Filelike) Close() error {
+	if x.stream == os.Stdin || x.stream == os.Stdout || x.stream == os.Stderr {
+		return nil
+	}
+	if closer, ok := x.stream.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return internal.Error(fmt.Sprintf("close error: %v", x), err, internal.ErrorDevice)
+		}
+	}
+	return nil
+}
+
+// SetSandbox allows guest to open files under root directory.
+// Guest file access is disabled without it.
+func (x *IOMap) SetSandbox(root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid sandbox root %s", root), err, internal.ErrorDevice)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	x.control.sandbox = abs
+	return nil
+}
+
+// resolve maps guest path into sandbox, refusing to escape it through symlinks
+func (x IOMap) resolve(guest string) (string, error) {
+	root := x.control.sandbox
+	if root == "" {
+		return "", ioFailure{code: IoPermission, err: fmt.Errorf("no sandbox set up")}
+	}
+	path := filepath.Join(root, filepath.Clean("/"+guest))
+	dir := filepath.Dir(path)
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", ioFailure{code: IoNotFound, err: err}
+	}
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		resolved, path = filepath.Dir(target), target
+	} else if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		// Dangling link would be followed when creating the file
+		return "", ioFailure{code: IoOutsideSandbox, err: fmt.Errorf("%s is a dangling link", guest)}
+	}
+	if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return "", ioFailure{code: IoOutsideSandbox, err: fmt.Errorf("%s is outside of sandbox", guest)}
+	}
+	return path, nil
+}
+
+func (x IOMap) freeDescriptor() (FileDescriptor, error) {
+	for fd := firstGuestDescriptor; fd < _fileDescriptorsLimt; fd++ {
+		if _, ok := x.fds[fd]; !ok {
+			return fd, nil
+		}
+	}
+	return 0, ioFailure{code: IoNoDescriptors, err: fmt.Errorf("out of descriptors")}
+}
+
+func (x IOMap) open() (uint16, error) {
+	path, err := x.resolve(string(x.control.path))
+	if err != nil {
+		return 0, err
+	}
+	fd, err := x.freeDescriptor()
+	if err != nil {
+		return 0, err
+	}
+	file, err := OpenFile(fd, path, AccessType(x.control.mode))
+	if err != nil {
+		return 0, err
+	}
+	x.fds[fd] = file
+	if x.control.opened == nil {
+		x.control.opened = map[FileDescriptor]bool{}
+	}
+	x.control.opened[fd] = true
+	return uint16(fd), nil
+}
+
+func (x IOMap) close(fd FileDescriptor) error {
+	file, ok := x.fds[fd]
+	if !ok {
+		return ioFailure{code: IoBadDescriptor, err: fmt.Errorf("%s is not open", fd)}
+	}
+	delete(x.fds, fd)
+	delete(x.control.opened, fd)
+	return file.Close()
+}
+
+// CloseDescriptor closes and removes descriptor
+func (x *IOMap) CloseDescriptor(fd FileDescriptor) error {
+	return x.close(fd)
+}
+
+// closeGuest closes descriptor on guest request, which is only
+// allowed for descriptors the guest opened itself
+func (x IOMap) closeGuest(fd FileDescriptor) error {
+	if !x.control.opened[fd] {
+		return ioFailure{code: IoBadDescriptor, err: fmt.Errorf("%s is not opened by guest", fd)}
+	}
+	return x.close(fd)
+}
+
+func (x IOMap) seek() (uint16, error) {
+	file, ok := x.fds[FileDescriptor(x.control.descriptor)]
+	if !ok {
+		return 0, ioFailure{code: IoBadDescriptor, err: fmt.Errorf("%s is not open", FileDescriptor(x.control.descriptor))}
+	}
+	offset := int64(x.control.offset)
+	if x.control.whence != io.SeekStart {
+		offset = int64(int16(x.control.offset))
+	}
+	pos, err := file.Seek(offset, int(x.control.whence))
+	if err != nil {
+		return 0, err
+	}
+	if pos > 0xffff {
+		return 0xffff, ioFailure{code: IoOverflow, err: fmt.Errorf("position %d does not fit result", pos)}
+	}
+	return uint16(pos), nil
+}
+
+func (x IOMap) command(cmd uint16) {
+	var result uint16
+	var err error
+	switch cmd {
+	case IoOpen:
+		result, err = x.open()
+	case IoClose:
+		err = x.closeGuest(FileDescriptor(x.control.descriptor))
+	case IoSeek:
+		result, err = x.seek()
+	default:
+		err = ioFailure{code: IoBadCommand, err: fmt.Errorf("unknown command %d", cmd)}
+	}
+	x.control.result = result
+	x.control.errno = ErrorCode(err)
+}
+
+// CloseAll closes all descriptors but standard streams, e.g. once machine is done
+func (x *IOMap) CloseAll() error {
+	var first error
+	for fd := range x.fds {
+		if fd == Stdin || fd == Stdout || fd == Stderr {
+			continue
+		}
+		if err := x.close(fd); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (x IOMap) getControl(at memory.Address) (uint16, error) {
+	switch at {
+	case IoMode:
+		return x.control.mode, nil
+	case IoDescriptor:
+		return x.control.descriptor, nil
+	case IoOffset:
+		return x.control.offset, nil
+	case IoWhence:
+		return x.control.whence, nil
+	case IoResult:
+		return x.control.result, nil
+	case IoError:
+		return x.control.errno, nil
+	case IoStatus:
+		if file, ok := x.fds[FileDescriptor(x.control.descriptor)]; ok {
+			return file.Status(), nil
+		}
+		return 0, nil
+	case IoAvailable:
+		file, ok := x.fds[FileDescriptor(x.control.descriptor)]
+		if !ok {
+			return 0, nil
+		}
+		available, known := file.Available()
+		if !known {
+			return IoUnknownAvailable, nil
+		}
+		if available >= int(IoUnknownAvailable) {
+			return IoUnknownAvailable - 1, nil
+		}
+		return uint16(available), nil
+	case IoPath, IoCommand:
+		return 0, nil
+	}
+	return 0, internal.Error(fmt.Sprintf("unknown io control address %#04x", at), nil, internal.ErrorDevice)
+}
+
+func (x IOMap) setControl(at memory.Address, value uint16) error {
+	switch at {
+	case IoPath:
+		if value == 0 {
+			x.control.path = x.control.path[:0]
+		} else {
+			x.control.path = append(x.control.path, byte(value))
+		}
+	case IoMode:
+		x.control.mode = value
+	case IoCommand:
+		x.command(value)
+	case IoDescriptor:
+		x.control.descriptor = value
+	case IoOffset:
+		x.control.offset = value
+	case IoWhence:
+		x.control.whence = value
+	default:
+		return internal.Error(fmt.Sprintf("unable to write io control address %#04x", at), nil, internal.ErrorDevice)
+	}
+	return nil
+}
+
+// ioDevice closes guest descriptors once detached
+type ioDevice struct {
+	Passive
+	iomap *IOMap
+}
+
+func (x ioDevice) Detach() error {
+	return x.iomap.CloseAll()
+}
+
+func newIoDevice(params Params) (Device, error) {
+	iomap := NewIoMap().(*IOMap)
+	if root := params.String("sandbox", ""); root != "" {
+		if err := iomap.SetSandbox(root); err != nil {
+			return nil, err
+		}
+	}
+	return ioDevice{Passive: Passive{mem: iomap}, iomap: iomap}, nil
+}
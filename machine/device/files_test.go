@@ -0,0 +1,262 @@
+package device
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"the-machine/machine/memory"
+)
+
+func openGuestFile(t *testing.T, iomap *IOMap, path string, mode AccessType) (FileDescriptor, uint16) {
+	t.Helper()
+	iomap.SetByte(IoPath, 0)
+	for _, c := range []byte(path) {
+		if err := iomap.SetByte(IoPath, c); err != nil {
+			t.Fatalf("unexpected error writing path: %v", err)
+		}
+	}
+	iomap.SetUint16(IoMode, uint16(mode))
+	iomap.SetUint16(IoCommand, IoOpen)
+	fd, _ := iomap.GetUint16(IoResult)
+	errno, _ := iomap.GetUint16(IoError)
+	return FileDescriptor(fd), errno
+}
+
+func Test_GuestFileRoundTrip(t *testing.T) {
+	iomap := NewIoMap().(*IOMap)
+	if err := iomap.SetSandbox(t.TempDir()); err != nil {
+		t.Fatalf("unexpected sandbox error: %v", err)
+	}
+
+	fd, errno := openGuestFile(t, iomap, "hello.txt", Read|Write)
+	if errno != IoOk {
+		t.Fatalf("expected file to open, got error %d", errno)
+	}
+	if fd < firstGuestDescriptor {
+		t.Fatalf("expected guest descriptor, got %d", fd)
+	}
+	for _, c := range []byte("hi") {
+		if err := iomap.SetByte(memory.Address(fd), c); err != nil {
+			t.Fatalf("unexpected write error: %v", err)
+		}
+	}
+
+	iomap.SetUint16(IoDescriptor, uint16(fd))
+	iomap.SetUint16(IoOffset, 1)
+	iomap.SetUint16(IoWhence, 0)
+	iomap.SetUint16(IoCommand, IoSeek)
+	if pos, _ := iomap.GetUint16(IoResult); pos != 1 {
+		t.Fatalf("expected seek to 1, got %d", pos)
+	}
+	if b, err := iomap.GetByte(memory.Address(fd)); err != nil || b != 'i' {
+		t.Fatalf("expected to read back 'i', got %q (%v)", b, err)
+	}
+
+	iomap.SetUint16(IoCommand, IoClose)
+	if errno, _ := iomap.GetUint16(IoError); errno != IoOk {
+		t.Fatalf("expected close to succeed, got %d", errno)
+	}
+	iomap.SetUint16(IoCommand, IoClose)
+	if errno, _ := iomap.GetUint16(IoError); errno != IoBadDescriptor {
+		t.Fatalf("expected bad descriptor on double close, got %d", errno)
+	}
+	if _, err := iomap.GetByte(memory.Address(fd)); err == nil {
+		t.Fatalf("expected closed descriptor to be gone")
+	}
+}
+
+func Test_GuestFileErrors(t *testing.T) {
+	iomap := NewIoMap().(*IOMap)
+	if _, errno := openGuestFile(t, iomap, "x.txt", Read); errno != IoPermission {
+		t.Fatalf("expected permission error without sandbox, got %d", errno)
+	}
+
+	outside := t.TempDir()
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("s"), 0644)
+	os.Symlink(outside, filepath.Join(root, "link"))
+	iomap.SetSandbox(root)
+
+	if _, errno := openGuestFile(t, iomap, "missing.txt", Read); errno != IoNotFound {
+		t.Fatalf("expected not found, got %d", errno)
+	}
+	if _, errno := openGuestFile(t, iomap, "link/secret", Read); errno != IoOutsideSandbox {
+		t.Fatalf("expected symlink escape to be refused, got %d", errno)
+	}
+	fd, errno := openGuestFile(t, iomap, "../../secret.txt", Write)
+	if errno != IoOk {
+		t.Fatalf("expected dot-dot to stay in sandbox, got %d", errno)
+	}
+	if _, err := os.Stat(filepath.Join(root, "secret.txt")); err != nil {
+		t.Fatalf("expected file created inside sandbox: %v", err)
+	}
+	if _, err := iomap.GetByte(memory.Address(fd)); err == nil {
+		t.Fatalf("expected write-only descriptor to refuse reads")
+	}
+	iomap.SetUint16(IoCommand, 42)
+	if errno, _ := iomap.GetUint16(IoError); errno != IoBadCommand {
+		t.Fatalf("expected bad command, got %d", errno)
+	}
+	if err := iomap.CloseAll(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func Test_DescriptorStatus(t *testing.T) {
+	iomap := NewIoMap().(*IOMap)
+	iomap.SetDescriptor(13, NewFilelike(13, Read, strings.NewReader("a\x00")))
+	iomap.SetDescriptor(14, NewFilelike(14, Write, failingWriter{}))
+	iomap.SetUint16(IoDescriptor, 13)
+
+	if available, _ := iomap.GetUint16(IoAvailable); available != 2 {
+		t.Fatalf("expected 2 bytes available, got %d", available)
+	}
+	for _, expected := range []byte("a\x00") {
+		b, err := iomap.GetByte(13)
+		if err != nil || b != expected {
+			t.Fatalf("expected %q, got %q (%v)", expected, b, err)
+		}
+		if status, _ := iomap.GetUint16(IoStatus); status != FdOpen|FdReadable {
+			t.Fatalf("expected open readable descriptor, got %#x", status)
+		}
+	}
+	if b, err := iomap.GetByte(13); err != nil || b != 0 {
+		t.Fatalf("expected zero at EOF without error, got %q (%v)", b, err)
+	}
+	if status, _ := iomap.GetUint16(IoStatus); status&FdEOF == 0 {
+		t.Fatalf("expected EOF in status, got %#x", status)
+	}
+	if available, _ := iomap.GetUint16(IoAvailable); available != 0 {
+		t.Fatalf("expected nothing available, got %d", available)
+	}
+
+	if err := iomap.SetByte(14, 'x'); err != nil {
+		t.Fatalf("expected write failure to be reported to guest only, got %v", err)
+	}
+	iomap.SetUint16(IoDescriptor, 14)
+	status, _ := iomap.GetUint16(IoStatus)
+	if status&FdError == 0 || status>>8 != IoPermission || status&FdWritable == 0 {
+		t.Fatalf("expected permission error in status, got %#x", status)
+	}
+	if available, _ := iomap.GetUint16(IoAvailable); available != 0 {
+		t.Fatalf("expected nothing to read from write-only descriptor, got %d", available)
+	}
+
+	iomap.SetUint16(IoDescriptor, 99)
+	if status, _ := iomap.GetUint16(IoStatus); status != 0 {
+		t.Fatalf("expected closed descriptor status to be empty, got %#x", status)
+	}
+}
+
+func Test_FilelikeReadEOF(t *testing.T) {
+	file := NewFilelike(13, Read, strings.NewReader(""))
+	if _, err := file.Read(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+// stallingReader returns empty reads without error a number of times
+type stallingReader struct {
+	stalls int
+	data   io.Reader
+}
+
+func (x *stallingReader) Read(p []byte) (int, error) {
+	if x.stalls > 0 {
+		x.stalls--
+		return 0, nil
+	}
+	return x.data.Read(p)
+}
+
+func Test_FilelikeReadEmpty(t *testing.T) {
+	file := NewFilelike(13, Read, &stallingReader{stalls: 3, data: strings.NewReader("h")})
+	if b, err := file.Read(); err != nil || b != 'h' {
+		t.Fatalf("expected empty reads to be retried, got %q, %v", b, err)
+	}
+
+	file = NewFilelike(13, Read, &stallingReader{stalls: maxEmptyReads, data: strings.NewReader("h")})
+	if _, err := file.Read(); err == nil || err == io.EOF {
+		t.Fatalf("expected stalled reader to fail without EOF, got %v", err)
+	}
+	if file.Status()&FdEOF != 0 {
+		t.Fatalf("expected stalled reader not to be at EOF")
+	}
+}
+
+func Test_GuestCloseHostDescriptor(t *testing.T) {
+	iomap := NewIoMap().(*IOMap)
+	iomap.SetDescriptor(13, NewFilelike(13, Read, strings.NewReader("host")))
+	for _, fd := range []FileDescriptor{Stdin, Stdout, Stderr, 13} {
+		iomap.SetUint16(IoDescriptor, uint16(fd))
+		iomap.SetUint16(IoCommand, IoClose)
+		if errno, _ := iomap.GetUint16(IoError); errno != IoBadDescriptor {
+			t.Fatalf("expected guest close of host %s to be refused, got %d", fd, errno)
+		}
+		if status, _ := iomap.GetUint16(IoStatus); status&FdOpen == 0 {
+			t.Fatalf("expected host %s to stay open", fd)
+		}
+	}
+}
+
+func Test_GuestFileDanglingLink(t *testing.T) {
+	outside := t.TempDir()
+	root := t.TempDir()
+	target := filepath.Join(outside, "created.txt")
+	os.Symlink(target, filepath.Join(root, "dangling"))
+
+	iomap := NewIoMap().(*IOMap)
+	iomap.SetSandbox(root)
+	if _, errno := openGuestFile(t, iomap, "dangling", Write); errno != IoOutsideSandbox {
+		t.Fatalf("expected dangling link to be refused, got %d", errno)
+	}
+	if _, err := os.Lstat(target); err == nil {
+		t.Fatalf("expected no file created outside sandbox")
+	}
+}
+
+func Test_GuestFileSeek(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "data.bin"), []byte("abcdef"), 0644)
+	big, _ := os.Create(filepath.Join(root, "big.bin"))
+	big.Truncate(0x10000 + 10)
+	big.Close()
+
+	iomap := NewIoMap().(*IOMap)
+	iomap.SetSandbox(root)
+	fd, _ := openGuestFile(t, iomap, "data.bin", Read)
+	seek := func(offset int16, whence uint16) (uint16, uint16) {
+		iomap.SetUint16(IoDescriptor, uint16(fd))
+		iomap.SetUint16(IoOffset, uint16(offset))
+		iomap.SetUint16(IoWhence, whence)
+		iomap.SetUint16(IoCommand, IoSeek)
+		pos, _ := iomap.GetUint16(IoResult)
+		errno, _ := iomap.GetUint16(IoError)
+		return pos, errno
+	}
+	if pos, errno := seek(-2, 2); errno != IoOk || pos != 4 {
+		t.Fatalf("expected seek back from end to 4, got %d (%d)", pos, errno)
+	}
+	if b, _ := iomap.GetByte(memory.Address(fd)); b != 'e' {
+		t.Fatalf("expected 'e', got %q", b)
+	}
+	if pos, errno := seek(-3, 1); errno != IoOk || pos != 2 {
+		t.Fatalf("expected relative seek back to 2, got %d (%d)", pos, errno)
+	}
+	if _, errno := seek(-10, 1); errno == IoOk {
+		t.Fatalf("expected error seeking before start")
+	}
+
+	fd, _ = openGuestFile(t, iomap, "big.bin", Read)
+	if pos, errno := seek(0, 2); errno != IoOverflow || pos != 0xffff {
+		t.Fatalf("expected overflow seeking past 0xffff, got %d (%d)", pos, errno)
+	}
+}
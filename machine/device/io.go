@@ -46,6 +46,8 @@ func (x AccessType) String() string {
 		return "Read"
 	case Write:
 		return "Write"
+	case Read | Write:
+		return "Read/Write"
 	default:
 		return "Unknown"
 	}
@@ -55,6 +57,7 @@ type Filelike struct {
 	descriptor FileDescriptor
 	access     AccessType
 	stream     interface{}
+	status     *fileStatus
 }
 
 func NewFilelike(fd FileDescriptor, access AccessType, stream interface{}) Filelike {
@@ -62,6 +65,7 @@ func NewFilelike(fd FileDescriptor, access AccessType, stream interface{}) Filel
 		descriptor: fd,
 		access:     access,
 		stream:     stream,
+		status:     &fileStatus{},
 	}
 }
 
@@ -73,74 +77,76 @@ func (x Filelike) String() string {
 	return fmt.Sprintf("%s <%s>: %s", x.descriptor, x.access, stream)
 }
 
+// maxEmptyReads is how many times an empty read is retried before giving up
+const maxEmptyReads = 100
+
+// Read returns io.EOF once the stream is exhausted,
+// so that it can be told apart from a zero byte
 func (x Filelike) Read() (byte, error) {
-	if x.access != Read {
+	if x.access&Read == 0 {
 		return 0, internal.Error(fmt.Sprintf("unable to read file descriptor in %v", x), nil, internal.ErrorLoading)
 	}
 	if reader, ok := x.stream.(io.Reader); ok {
 		buf := make([]byte, 1, 1)
-		if n, err := reader.Read(buf); err != nil {
-			if io.EOF == err {
-				return 0, nil
+		// Empty reads without error are retried, as bufio does
+		var err error
+		for i := 0; i < maxEmptyReads; i++ {
+			var n int
+			n, err = reader.Read(buf)
+			if n > 0 {
+				x.record(nil)
+				return buf[0], nil
+			}
+			if err != nil {
+				break
 			}
-			return 0, internal.Error(fmt.Sprintf("read error: %v", x), err, internal.ErrorLoading)
-		} else if n > 0 {
-			return buf[0], nil
 		}
-		return 0, internal.Error(fmt.Sprintf("read error: EOF %v", x), nil, internal.ErrorLoading)
+		if err == nil {
+			err = io.ErrNoProgress
+		}
+		if err == io.EOF {
+			x.record(io.EOF)
+			return 0, io.EOF
+		}
+		return 0, x.record(internal.Error(fmt.Sprintf("read error: %v", x), err, internal.ErrorLoading))
 	}
 	return 0, internal.Error(fmt.Sprintf("not a reader: %v", x), nil, internal.ErrorLoading)
 }
 
 func (x Filelike) Write(b byte) error {
-	if x.access != Write {
-		return internal.Error(fmt.Sprintf("unable to write to file descriptor in %v", x), nil, internal.ErrorLoading)
-	}
-	if writer, ok := x.stream.(io.Writer); ok {
-		if _, err := writer.Write([]byte{b}); err != nil {
-			return internal.Error(fmt.Sprintf("write error: %v", x), err, internal.ErrorLoading)
-		}
-		return nil
-	}
-	return internal.Error(fmt.Sprintf("not a writer: %v", x), nil, internal.ErrorLoading)
+	return x.write([]byte{b})
 }
 
 func (x Filelike) WriteUint16(b []byte) error {
-	if x.access != Write {
+	return x.write(b)
+}
+
+func (x Filelike) write(b []byte) error {
+	if x.access&Write == 0 {
 		return internal.Error(fmt.Sprintf("unable to write to file descriptor in %v", x), nil, internal.ErrorLoading)
 	}
 	if writer, ok := x.stream.(io.Writer); ok {
 		if _, err := writer.Write(b); err != nil {
-			return internal.Error(fmt.Sprintf("write error: %v", x), err, internal.ErrorLoading)
+			return x.record(internal.Error(fmt.Sprintf("write error: %v", x), err, internal.ErrorLoading))
 		}
+		x.record(nil)
 		return nil
 	}
 	return internal.Error(fmt.Sprintf("not a writer: %v", x), nil, internal.ErrorLoading)
 }
 
 type IOMap struct {
-	fds map[FileDescriptor]Filelike
+	fds     map[FileDescriptor]Filelike
+	control *ioControl
 }
 
 func NewIoMap() memory.MemoryAccess {
 	fds := map[FileDescriptor]Filelike{
-		Stdin: Filelike{
-			descriptor: Stdin,
-			access:     Read,
-			stream:     os.Stdin,
-		},
-		Stdout: Filelike{
-			descriptor: Stdout,
-			access:     Write,
-			stream:     os.Stdout,
-		},
-		Stderr: Filelike{
-			descriptor: Stderr,
-			access:     Write,
-			stream:     os.Stderr,
-		},
-	}
-	return &IOMap{fds: fds}
+		Stdin:  NewFilelike(Stdin, Read, os.Stdin),
+		Stdout: NewFilelike(Stdout, Write, os.Stdout),
+		Stderr: NewFilelike(Stderr, Write, os.Stderr),
+	}
+	return &IOMap{fds: fds, control: &ioControl{}}
 }
 
 func memoryAddressToFileDescriptor(at memory.Address) (FileDescriptor, error) {
@@ -154,6 +160,10 @@ func memoryAddressToFileDescriptor(at memory.Address) (FileDescriptor, error) {
 }
 
 func (x IOMap) GetByte(at memory.Address) (byte, error) {
+	if at >= IoControl {
+		value, err := x.getControl(at &^ 1)
+		return byte(value >> (8 * (at & 1))), err
+	}
 	key, err := memoryAddressToFileDescriptor(at)
 	if err != nil {
 		return 0, internal.Error(
@@ -162,7 +172,11 @@ func (x IOMap) GetByte(at memory.Address) (byte, error) {
 			internal.ErrorLoading)
 	}
 	if reader, ok := x.fds[key]; ok {
-		return reader.Read()
+		b, err := reader.Read()
+		if isStreamError(err) {
+			return b, nil
+		}
+		return b, err
 	}
 	return 0, internal.Error(
 		fmt.Sprintf("not a descriptor: %v", x),
@@ -171,6 +185,9 @@ func (x IOMap) GetByte(at memory.Address) (byte, error) {
 
 }
 func (x IOMap) SetByte(at memory.Address, b byte) error {
+	if at >= IoControl {
+		return x.setControl(at, uint16(b))
+	}
 	key, err := memoryAddressToFileDescriptor(at)
 	if err != nil {
 		return internal.Error(
@@ -179,7 +196,10 @@ func (x IOMap) SetByte(at memory.Address, b byte) error {
 			internal.ErrorLoading)
 	}
 	if writer, ok := x.fds[key]; ok {
-		return writer.Write(b)
+		if err := writer.Write(b); !isStreamError(err) {
+			return err
+		}
+		return nil
 	}
 	return internal.Error(
 		fmt.Sprintf("not a descriptor: %v", x),
@@ -189,6 +209,9 @@ func (x IOMap) SetByte(at memory.Address, b byte) error {
 }
 
 func (x IOMap) GetUint16(at memory.Address) (uint16, error) {
+	if at >= IoControl {
+		return x.getControl(at)
+	}
 	val, err := x.GetByte(at)
 	if err != nil {
 		return uint16(val), err
@@ -196,6 +219,9 @@ func (x IOMap) GetUint16(at memory.Address) (uint16, error) {
 	return uint16(val), nil
 }
 func (x IOMap) SetUint16(at memory.Address, what uint16) error {
+	if at >= IoControl {
+		return x.setControl(at, what)
+	}
 	key, err := memoryAddressToFileDescriptor(at)
 	if err != nil {
 		return internal.Error(
@@ -208,7 +234,10 @@ func (x IOMap) SetUint16(at memory.Address, what uint16) error {
 	binary.LittleEndian.PutUint16(b, what)
 
 	if writer, ok := x.fds[key]; ok {
-		return writer.WriteUint16(b)
+		if err := writer.WriteUint16(b); !isStreamError(err) {
+			return err
+		}
+		return nil
 	}
 	return internal.Error(
 		fmt.Sprintf("not a descriptor: %v", x),
@@ -216,6 +245,13 @@ func (x IOMap) SetUint16(at memory.Address, what uint16) error {
 		internal.ErrorLoading)
 }
 
+// GetDescriptor gives access to descriptor, if there is one
+func (x IOMap) GetDescriptor(fd FileDescriptor) (Filelike, bool) {
+	file, ok := x.fds[fd]
+	return file, ok
+}
+
 func (x *IOMap) SetDescriptor(fd FileDescriptor, what Filelike) {
+	delete(x.control.opened, fd)
 	x.fds[fd] = what
 }
@@ -1,6 +1,7 @@
 package device
 
 import (
+	"bytes"
 	"testing"
 	"the-machine/machine/memory"
 )
@@ -11,3 +12,28 @@ func Test_WriteToStdout(t *testing.T) {
 		t.Fatalf("unexpected error writing to stdout: %v", err)
 	}
 }
+
+func Test_ObservedIoWriteDoesNotRead(t *testing.T) {
+	iomap := NewIoMap().(*IOMap)
+	iomap.SetDescriptor(13, NewFilelike(13, Read|Write, bytes.NewBufferString("ab")))
+	observed := memory.NewObserved(memory.DeviceIO, iomap)
+	var writes []memory.Access
+	observed.AddObserver(memory.ObserverFunc(func(access memory.Access) {
+		if access.Operation.IsWrite() {
+			writes = append(writes, access)
+		}
+	}))
+
+	if err := observed.SetByte(13, 'c'); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	if b, _ := observed.GetByte(13); b != 'a' {
+		t.Fatalf("expected observed write to leave input alone, got %q", b)
+	}
+	if len(writes) != 1 || writes[0].New != 'c' {
+		t.Fatalf("expected write to be observed, got %v", writes)
+	}
+	if writes[0].OldKnown {
+		t.Fatalf("expected old value of device write to be unknown, got %v", writes[0])
+	}
+}
@@ -0,0 +1,217 @@
+package device
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+)
+
+// Keyboard registers
+const (
+	KeyboardStatus  memory.Address = 0 // Status flags, any write clears overflow
+	KeyboardData    memory.Address = 1 // Reading pops next key, 0 when there's none
+	KeyboardControl memory.Address = 2 // Control flags
+)
+
+// Keyboard status flags
+const (
+	KeyAvailable   byte = 1 << iota
+	KeyOverflow    byte = 1 << iota
+	KeyboardClosed byte = 1 << iota
+)
+
+// Keyboard control flags
+const (
+	KeyboardIrq byte = 1 << iota
+)
+
+const defaultKeyboardBuffer = 16
+
+// Keyboard buffers keystrokes read in background, so reading never blocks
+type Keyboard struct {
+	input   io.Reader
+	raw     bool
+	restore func() error
+
+	lock     sync.Mutex
+	buffer   []byte
+	head     int
+	count    int
+	overflow bool
+	closed   bool
+	control  byte
+	arrived  bool
+	started  bool
+}
+
+// NewKeyboard buffers up to size keystrokes read from input
+func NewKeyboard(input io.Reader, size int) *Keyboard {
+	if size <= 0 {
+		size = defaultKeyboardBuffer
+	}
+	return &Keyboard{input: input, buffer: make([]byte, size)}
+}
+
+// NewTerminalKeyboard reads keystrokes from stdin, switched to raw mode while attached
+func NewTerminalKeyboard(size int) *Keyboard {
+	kbd := NewKeyboard(os.Stdin, size)
+	kbd.raw = true
+	return kbd
+}
+
+func (x *Keyboard) Attach(memory.MemoryType) error {
+	if x.raw {
+		if f, ok := x.input.(*os.File); ok {
+			restore, err := enableRawMode(f)
+			if err != nil {
+				return internal.Error("unable to switch terminal to raw mode", err, internal.ErrorDevice)
+			}
+			x.restore = restore
+		}
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if !x.started {
+		x.started = true
+		go x.listen()
+	}
+	return nil
+}
+
+func (x *Keyboard) listen() {
+	b := make([]byte, 1)
+	for {
+		n, err := x.input.Read(b)
+		if n > 0 {
+			x.push(b[0])
+		}
+		if err != nil {
+			x.lock.Lock()
+			x.closed = true
+			x.lock.Unlock()
+			return
+		}
+	}
+}
+
+func (x *Keyboard) push(key byte) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.count == len(x.buffer) {
+		x.overflow = true
+		return
+	}
+	x.buffer[(x.head+x.count)%len(x.buffer)] = key
+	x.count++
+	x.arrived = true
+}
+
+func (x *Keyboard) pop() byte {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.count == 0 {
+		return 0
+	}
+	key := x.buffer[x.head]
+	x.head = (x.head + 1) % len(x.buffer)
+	x.count--
+	return key
+}
+
+func (x *Keyboard) status() byte {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	var status byte
+	if x.count > 0 {
+		status |= KeyAvailable
+	}
+	if x.overflow {
+		status |= KeyOverflow
+	}
+	if x.closed && x.count == 0 {
+		status |= KeyboardClosed
+	}
+	return status
+}
+
+func (x *Keyboard) GetByte(at memory.Address) (byte, error) {
+	switch at {
+	case KeyboardStatus:
+		return x.status(), nil
+	case KeyboardData:
+		return x.pop(), nil
+	case KeyboardControl:
+		x.lock.Lock()
+		defer x.lock.Unlock()
+		return x.control, nil
+	}
+	return 0, internal.Error(fmt.Sprintf("unknown keyboard register %d", at), nil, internal.ErrorDevice)
+}
+
+func (x *Keyboard) GetUint16(at memory.Address) (uint16, error) {
+	b, err := x.GetByte(at)
+	return uint16(b), err
+}
+
+func (x *Keyboard) SetByte(at memory.Address, value byte) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	switch at {
+	case KeyboardStatus:
+		x.overflow = false
+		return nil
+	case KeyboardControl:
+		x.control = value
+		return nil
+	}
+	return internal.Error(fmt.Sprintf("unable to write keyboard register %d", at), nil, internal.ErrorDevice)
+}
+
+func (x *Keyboard) SetUint16(at memory.Address, value uint16) error {
+	return x.SetByte(at, byte(value))
+}
+
+// Reset drops buffered keys and control flags
+func (x *Keyboard) Reset() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.head = 0
+	x.count = 0
+	x.overflow = false
+	x.control = 0
+	x.arrived = false
+}
+
+func (x *Keyboard) Tick(uint64) error {
+	return nil
+}
+
+// Detach restores terminal mode. Pending read in background is
+// left alone, there's no way to interrupt it.
+func (x *Keyboard) Detach() error {
+	if x.restore == nil {
+		return nil
+	}
+	restore := x.restore
+	x.restore = nil
+	if err := restore(); err != nil {
+		return internal.Error("unable to restore terminal mode", err, internal.ErrorDevice)
+	}
+	return nil
+}
+
+// PendingIrq is raised for keys arrived since last IRQ, if enabled
+func (x *Keyboard) PendingIrq() bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.control&KeyboardIrq != 0 && x.arrived
+}
+
+func (x *Keyboard) AcknowledgeIrq() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.arrived = false
+}
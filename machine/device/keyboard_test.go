@@ -0,0 +1,63 @@
+package device
+
+import (
+	"io"
+	"testing"
+	"the-machine/machine/memory"
+	"time"
+)
+
+func waitForStatus(t *testing.T, kbd *Keyboard, flag byte) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if status, _ := kbd.GetByte(KeyboardStatus); status&flag != 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for keyboard status %#02x", flag)
+}
+
+func Test_Keyboard(t *testing.T) {
+	r, w := io.Pipe()
+	kbd := NewKeyboard(r, 2)
+	if err := kbd.Attach(memory.DeviceKeyboard); err != nil {
+		t.Fatalf("unable to attach keyboard: %v", err)
+	}
+
+	if key, err := kbd.GetByte(KeyboardData); err != nil || key != 0 {
+		t.Fatalf("expected non-blocking read with no key, got %d and error %v", key, err)
+	}
+
+	kbd.SetByte(KeyboardControl, KeyboardIrq)
+	w.Write([]byte("hai"))
+	waitForStatus(t, kbd, KeyOverflow)
+
+	if !kbd.PendingIrq() {
+		t.Fatalf("expected IRQ on key arrival")
+	}
+	kbd.AcknowledgeIrq()
+	if kbd.PendingIrq() {
+		t.Fatalf("expected IRQ to be acknowledged")
+	}
+
+	if key, _ := kbd.GetByte(KeyboardData); key != 'h' {
+		t.Fatalf("expected first key, got %c", key)
+	}
+	if key, _ := kbd.GetByte(KeyboardData); key != 'a' {
+		t.Fatalf("expected second key, got %c", key)
+	}
+	if status, _ := kbd.GetByte(KeyboardStatus); status&KeyAvailable != 0 || status&KeyOverflow == 0 {
+		t.Fatalf("expected empty buffer with overflow flag, got %#02x", status)
+	}
+	kbd.SetByte(KeyboardStatus, 0)
+	if status, _ := kbd.GetByte(KeyboardStatus); status&KeyOverflow != 0 {
+		t.Fatalf("expected overflow flag cleared, got %#02x", status)
+	}
+
+	w.Close()
+	waitForStatus(t, kbd, KeyboardClosed)
+	if err := kbd.Detach(); err != nil {
+		t.Fatalf("unable to detach keyboard: %v", err)
+	}
+}
@@ -0,0 +1,593 @@
+package device
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+	"time"
+)
+
+const (
+	NetBufferSize = 256
+	NetMaxSockets = 16
+
+	// netQueue is number of received chunks, or accepted connections,
+	// waiting for the guest before host side stops reading
+	netQueue = 16
+)
+
+// Socket registers are uint16, data buffer is a window of NetBufferSize bytes
+const (
+	NetCommand memory.Address = 0x00 // Write starts a command
+	NetSocket  memory.Address = 0x02 // Socket handle for accept, send, recv and close
+	NetAddress memory.Address = 0x04 // Byte writes append to "host:port", zero byte clears it
+	NetLength  memory.Address = 0x06 // Bytes to send, or to receive at most
+	NetResult  memory.Address = 0x08 // New socket handle, or number of bytes transferred
+	NetStatus  memory.Address = 0x0a // Status flags of the socket
+	NetError   memory.Address = 0x0c // Error code of the last command
+	NetPort    memory.Address = 0x0e // Local port of the socket
+	NetBuffer  memory.Address = 0x100
+)
+
+// Socket commands
+const (
+	NetConnect uint16 = 1 // Connect to address, socket is NetConnecting until done
+	NetListen  uint16 = 2 // Listen on address
+	NetAccept  uint16 = 3 // Accept pending connection on listening socket
+	NetSend    uint16 = 4 // Queue length bytes from buffer for sending
+	NetRecv    uint16 = 5 // Receive up to length bytes into buffer
+	NetClose   uint16 = 6 // Close socket
+)
+
+// Socket status flags
+const (
+	NetOpen       uint16 = 1 << iota
+	NetListening  uint16 = 1 << iota
+	NetReadable   uint16 = 1 << iota // Data waiting to be received
+	NetPending    uint16 = 1 << iota // Connection waiting to be accepted
+	NetClosed     uint16 = 1 << iota // Peer hung up, connecting or sending failed
+	NetConnecting uint16 = 1 << iota // Connection not established yet
+)
+
+// Socket error codes
+const (
+	NetOk         uint16 = 0
+	NetDenied     uint16 = 1
+	NetBadAddress uint16 = 2
+	NetBadSocket  uint16 = 3
+	NetBadCommand uint16 = 4
+	NetNoSockets  uint16 = 5
+	NetRefused    uint16 = 6
+	NetWouldBlock uint16 = 7
+	NetHangup     uint16 = 8
+	NetIoError    uint16 = 9
+)
+
+// socket is either a connection or a listener. Host side connects, reads,
+// writes and accepts happen in background, so that guest commands never
+// block on the network.
+type socket struct {
+	conn     net.Conn
+	listener net.Listener
+	timeout  time.Duration
+	dialling chan net.Conn // Delivers connection once dialled, closed on failure
+	chunks   chan []byte   // Received data, closed once peer hangs up
+	outgoing chan []byte   // Data queued for sending, closed with the socket
+	failed   chan struct{} // Closed once sending fails
+	accepted chan net.Conn // Accepted connections, closed once listener stops
+	done     chan struct{}
+	leftover []byte
+	hangup   bool
+	refused  bool
+}
+
+func newSocket(timeout time.Duration) *socket {
+	return &socket{
+		timeout:  timeout,
+		chunks:   make(chan []byte, netQueue),
+		outgoing: make(chan []byte, netQueue),
+		failed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func newConnection(conn net.Conn, timeout time.Duration) *socket {
+	x := newSocket(timeout)
+	x.start(conn)
+	return x
+}
+
+func newDialling(address string, timeout time.Duration) *socket {
+	x := newSocket(timeout)
+	x.dialling = make(chan net.Conn, 1)
+	go x.dial(address)
+	return x
+}
+
+func newListener(listener net.Listener) *socket {
+	x := &socket{listener: listener, accepted: make(chan net.Conn, netQueue), done: make(chan struct{})}
+	go x.accept()
+	return x
+}
+
+func (x *socket) start(conn net.Conn) {
+	x.conn = conn
+	go x.receive()
+	go x.transmit()
+}
+
+func (x *socket) dial(address string) {
+	conn, err := net.DialTimeout("tcp", address, x.timeout)
+	if err != nil {
+		close(x.dialling)
+		return
+	}
+	x.dialling <- conn
+}
+
+// established picks up dialled connection, once there is one
+func (x *socket) established() bool {
+	if x.conn != nil {
+		return true
+	}
+	if x.dialling == nil || x.refused {
+		return false
+	}
+	select {
+	case conn, ok := <-x.dialling:
+		if !ok {
+			x.refused = true
+			return false
+		}
+		x.start(conn)
+		return true
+	default:
+		return false
+	}
+}
+
+func (x *socket) receive() {
+	defer close(x.chunks)
+	for {
+		buf := make([]byte, NetBufferSize)
+		n, err := x.conn.Read(buf)
+		if n > 0 {
+			select {
+			case x.chunks <- buf[:n]:
+			case <-x.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// transmit sends queued data until the socket is closed,
+// then closes the connection
+func (x *socket) transmit() {
+	for data := range x.outgoing {
+		x.conn.SetWriteDeadline(time.Now().Add(x.timeout))
+		if _, err := x.conn.Write(data); err != nil {
+			close(x.failed)
+			break
+		}
+	}
+	x.conn.Close()
+	for range x.outgoing {
+		// Drop whatever is queued after failure
+	}
+}
+
+func (x *socket) sendFailed() bool {
+	select {
+	case <-x.failed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (x *socket) accept() {
+	defer close(x.accepted)
+	for {
+		conn, err := x.listener.Accept()
+		if err != nil {
+			return
+		}
+		select {
+		case x.accepted <- conn:
+		case <-x.done:
+			conn.Close()
+			return
+		}
+	}
+}
+
+// fill moves next received chunk, if any, to leftover
+func (x *socket) fill() {
+	for len(x.leftover) == 0 && !x.hangup {
+		select {
+		case chunk, ok := <-x.chunks:
+			if !ok {
+				x.hangup = true
+				return
+			}
+			x.leftover = chunk
+		default:
+			return
+		}
+	}
+}
+
+func (x *socket) status() uint16 {
+	status := NetOpen
+	if x.listener != nil {
+		status |= NetListening
+		if len(x.accepted) > 0 {
+			status |= NetPending
+		}
+		return status
+	}
+	if !x.established() {
+		if x.refused {
+			return NetClosed
+		}
+		return NetConnecting
+	}
+	x.fill()
+	if len(x.leftover) > 0 {
+		status |= NetReadable
+	}
+	if x.hangup || x.sendFailed() {
+		status |= NetClosed
+	}
+	return status
+}
+
+func (x *socket) port() uint16 {
+	var addr net.Addr
+	if x.listener != nil {
+		addr = x.listener.Addr()
+	} else if x.established() {
+		addr = x.conn.LocalAddr()
+	}
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return uint16(tcp.Port)
+	}
+	return 0
+}
+
+func (x *socket) close() error {
+	close(x.done)
+	if x.listener != nil {
+		err := x.listener.Close()
+		for conn := range x.accepted {
+			conn.Close()
+		}
+		return err
+	}
+	// Connection is closed once queued data is sent
+	close(x.outgoing)
+	if x.conn == nil && !x.refused {
+		go func() {
+			if conn, ok := <-x.dialling; ok {
+				conn.Close()
+			}
+		}()
+	}
+	return nil
+}
+
+// Net is TCP socket device, restricted to allowed hosts
+type Net struct {
+	allow   []string
+	timeout time.Duration
+	sockets map[uint16]*socket
+	address []byte
+	socket  uint16
+	length  uint16
+	result  uint16
+	errno   uint16
+	buffer  [NetBufferSize]byte
+}
+
+// NewNet allows connecting to and listening on listed addresses,
+// either as "host" for any port or "host:port"
+func NewNet(allow []string, timeout time.Duration) *Net {
+	return &Net{allow: allow, timeout: timeout, sockets: map[uint16]*socket{}}
+}
+
+func (x *Net) allowed(address string) (bool, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range x.allow {
+		if entry == address || entry == host {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (x *Net) free() (uint16, bool) {
+	for handle := uint16(1); handle <= NetMaxSockets; handle++ {
+		if _, ok := x.sockets[handle]; !ok {
+			return handle, true
+		}
+	}
+	return 0, false
+}
+
+func (x *Net) open(command uint16) uint16 {
+	address := string(x.address)
+	ok, err := x.allowed(address)
+	if err != nil {
+		return NetBadAddress
+	}
+	if !ok {
+		return NetDenied
+	}
+	handle, ok := x.free()
+	if !ok {
+		return NetNoSockets
+	}
+	if command == NetListen {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return NetIoError
+		}
+		x.sockets[handle] = newListener(listener)
+	} else {
+		x.sockets[handle] = newDialling(address, x.timeout)
+	}
+	x.result = handle
+	return NetOk
+}
+
+func (x *Net) acceptConnection(s *socket) uint16 {
+	if s.listener == nil {
+		return NetBadSocket
+	}
+	handle, ok := x.free()
+	if !ok {
+		return NetNoSockets
+	}
+	select {
+	case conn, ok := <-s.accepted:
+		if !ok {
+			return NetHangup
+		}
+		x.sockets[handle] = newConnection(conn, x.timeout)
+		x.result = handle
+		return NetOk
+	default:
+		return NetWouldBlock
+	}
+}
+
+// connected tells whether socket is ready for sending and receiving,
+// with error code if it is not
+func connected(s *socket) (bool, uint16) {
+	switch {
+	case s.listener != nil:
+		return false, NetBadSocket
+	case s.established():
+		return true, NetOk
+	case s.refused:
+		return false, NetRefused
+	}
+	return false, NetWouldBlock
+}
+
+func (x *Net) send(s *socket) uint16 {
+	if ok, errno := connected(s); !ok {
+		return errno
+	}
+	if s.sendFailed() {
+		return NetIoError
+	}
+	length := int(x.length)
+	if length > NetBufferSize {
+		length = NetBufferSize
+	}
+	data := make([]byte, length)
+	copy(data, x.buffer[:length])
+	select {
+	case s.outgoing <- data:
+		x.result = uint16(length)
+		return NetOk
+	default:
+		return NetWouldBlock
+	}
+}
+
+func (x *Net) recv(s *socket) uint16 {
+	if ok, errno := connected(s); !ok {
+		return errno
+	}
+	s.fill()
+	if len(s.leftover) == 0 {
+		if s.hangup {
+			return NetHangup
+		}
+		return NetWouldBlock
+	}
+	length := int(x.length)
+	if length > NetBufferSize {
+		length = NetBufferSize
+	}
+	n := copy(x.buffer[:length], s.leftover)
+	s.leftover = s.leftover[n:]
+	x.result = uint16(n)
+	return NetOk
+}
+
+func (x *Net) run(command uint16) {
+	x.result = 0
+	if command == NetConnect || command == NetListen {
+		x.errno = x.open(command)
+		return
+	}
+	s, ok := x.sockets[x.socket]
+	switch {
+	case command < NetConnect || command > NetClose:
+		x.errno = NetBadCommand
+	case !ok:
+		x.errno = NetBadSocket
+	case command == NetAccept:
+		x.errno = x.acceptConnection(s)
+	case command == NetSend:
+		x.errno = x.send(s)
+	case command == NetRecv:
+		x.errno = x.recv(s)
+	case command == NetClose:
+		delete(x.sockets, x.socket)
+		x.errno = NetOk
+		if err := s.close(); err != nil {
+			x.errno = NetIoError
+		}
+	}
+}
+
+func (x *Net) get(reg memory.Address) (uint16, error) {
+	switch reg {
+	case NetCommand, NetAddress:
+		return 0, nil
+	case NetSocket:
+		return x.socket, nil
+	case NetLength:
+		return x.length, nil
+	case NetResult:
+		return x.result, nil
+	case NetStatus:
+		if s, ok := x.sockets[x.socket]; ok {
+			return s.status(), nil
+		}
+		return 0, nil
+	case NetError:
+		return x.errno, nil
+	case NetPort:
+		if s, ok := x.sockets[x.socket]; ok {
+			return s.port(), nil
+		}
+		return 0, nil
+	}
+	return 0, internal.Error(fmt.Sprintf("unknown net register %d", reg), nil, internal.ErrorDevice)
+}
+
+func (x *Net) set(reg memory.Address, value uint16) error {
+	switch reg {
+	case NetCommand:
+		x.run(value)
+	case NetSocket:
+		x.socket = value
+	case NetAddress:
+		if value == 0 {
+			x.address = x.address[:0]
+		} else {
+			x.address = append(x.address, byte(value))
+		}
+	case NetLength:
+		x.length = value
+	default:
+		return internal.Error(fmt.Sprintf("unable to write net register %d", reg), nil, internal.ErrorDevice)
+	}
+	return nil
+}
+
+func inNetBuffer(at memory.Address, size int) bool {
+	return at >= NetBuffer && int(at)+size <= int(NetBuffer)+NetBufferSize
+}
+
+func (x *Net) GetByte(at memory.Address) (byte, error) {
+	if inNetBuffer(at, 1) {
+		return x.buffer[at-NetBuffer], nil
+	}
+	value, err := x.get(at &^ 1)
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, value)
+	return b[at&1], err
+}
+
+func (x *Net) GetUint16(at memory.Address) (uint16, error) {
+	if inNetBuffer(at, 2) {
+		offset := at - NetBuffer
+		return binary.LittleEndian.Uint16(x.buffer[offset : offset+2]), nil
+	}
+	return x.get(at)
+}
+
+// SetByte writes to data buffer, or sets whole register
+func (x *Net) SetByte(at memory.Address, value byte) error {
+	if inNetBuffer(at, 1) {
+		x.buffer[at-NetBuffer] = value
+		return nil
+	}
+	return x.set(at, uint16(value))
+}
+
+func (x *Net) SetUint16(at memory.Address, value uint16) error {
+	if inNetBuffer(at, 2) {
+		offset := at - NetBuffer
+		binary.LittleEndian.PutUint16(x.buffer[offset:offset+2], value)
+		return nil
+	}
+	return x.set(at, value)
+}
+
+func (x *Net) Attach(memory.MemoryType) error {
+	return nil
+}
+
+// Reset closes all sockets and clears registers
+func (x *Net) Reset() {
+	x.closeAll()
+	x.address = nil
+	x.socket = 0
+	x.length = 0
+	x.result = 0
+	x.errno = NetOk
+	x.buffer = [NetBufferSize]byte{}
+}
+
+func (x *Net) Tick(uint64) error {
+	return nil
+}
+
+func (x *Net) Detach() error {
+	if err := x.closeAll(); err != nil {
+		return internal.Error("unable to close sockets", err, internal.ErrorDevice)
+	}
+	return nil
+}
+
+func (x *Net) closeAll() error {
+	var first error
+	for handle, s := range x.sockets {
+		delete(x.sockets, handle)
+		if err := s.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func newNetDevice(params Params) (Device, error) {
+	var allow []string
+	for _, entry := range strings.Split(params.String("allow", ""), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			allow = append(allow, entry)
+		}
+	}
+	timeout, err := params.Int("timeout_ms", 1000)
+	if err != nil {
+		return nil, err
+	}
+	return NewNet(allow, time.Duration(timeout)*time.Millisecond), nil
+}
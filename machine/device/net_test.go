@@ -0,0 +1,221 @@
+package device
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"the-machine/machine/memory"
+	"time"
+)
+
+func netCommand(x *Net, address string, command uint16) (uint16, uint16) {
+	x.SetByte(NetAddress, 0)
+	for _, c := range []byte(address) {
+		x.SetByte(NetAddress, c)
+	}
+	x.SetUint16(NetCommand, command)
+	result, _ := x.GetUint16(NetResult)
+	errno, _ := x.GetUint16(NetError)
+	return result, errno
+}
+
+// poll retries command while it would block
+func poll(t *testing.T, x *Net, command uint16) uint16 {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		x.SetUint16(NetCommand, command)
+		if errno, _ := x.GetUint16(NetError); errno != NetWouldBlock {
+			if errno != NetOk {
+				t.Fatalf("command %d failed with %d", command, errno)
+			}
+			result, _ := x.GetUint16(NetResult)
+			return result
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("command %d timed out", command)
+	return 0
+}
+
+func Test_Net_ListenAccept(t *testing.T) {
+	dev := NewNet([]string{"127.0.0.1"}, time.Second)
+	defer dev.Detach()
+
+	listener, errno := netCommand(dev, "127.0.0.1:0", NetListen)
+	if errno != NetOk {
+		t.Fatalf("unable to listen: %d", errno)
+	}
+	dev.SetUint16(NetSocket, listener)
+	port, _ := dev.GetUint16(NetPort)
+	if status, _ := dev.GetUint16(NetStatus); status != NetOpen|NetListening {
+		t.Fatalf("expected listening socket, got %#x", status)
+	}
+	dev.SetUint16(NetCommand, NetAccept)
+	if errno, _ := dev.GetUint16(NetError); errno != NetWouldBlock {
+		t.Fatalf("expected accept to would-block without clients, got %d", errno)
+	}
+
+	client, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("unable to connect to guest: %v", err)
+	}
+	defer client.Close()
+	client.Write([]byte("ping"))
+
+	conn := poll(t, dev, NetAccept)
+	dev.SetUint16(NetSocket, conn)
+	dev.SetUint16(NetLength, NetBufferSize)
+	if n := poll(t, dev, NetRecv); n != 4 {
+		t.Fatalf("expected 4 bytes, got %d", n)
+	}
+	for i, expected := range []byte("ping") {
+		if b, _ := dev.GetByte(NetBuffer + memory.Address(i)); b != expected {
+			t.Fatalf("expected %q at %d, got %q", expected, i, b)
+		}
+	}
+
+	copy(dev.buffer[:], "pong")
+	dev.SetUint16(NetLength, 4)
+	dev.SetUint16(NetCommand, NetSend)
+	if n, _ := dev.GetUint16(NetResult); n != 4 {
+		t.Fatalf("expected 4 bytes sent, got %d", n)
+	}
+	reply := make([]byte, 4)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(client, reply); err != nil || string(reply) != "pong" {
+		t.Fatalf("expected pong, got %q (%v)", reply, err)
+	}
+
+	client.Close()
+	deadline := time.Now().Add(time.Second)
+	for status, _ := dev.GetUint16(NetStatus); status&NetClosed == 0; status, _ = dev.GetUint16(NetStatus) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected hangup to show in status")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	dev.SetUint16(NetCommand, NetRecv)
+	if errno, _ := dev.GetUint16(NetError); errno != NetHangup {
+		t.Fatalf("expected hangup, got %d", errno)
+	}
+	dev.SetUint16(NetCommand, NetClose)
+	if status, _ := dev.GetUint16(NetStatus); status != 0 {
+		t.Fatalf("expected closed socket to have no status, got %#x", status)
+	}
+}
+
+func Test_Net_Connect(t *testing.T) {
+	host, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer host.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := host.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf, _ := io.ReadAll(conn)
+		received <- string(buf)
+	}()
+
+	dev := NewNet([]string{host.Addr().String()}, time.Second)
+	defer dev.Detach()
+
+	if _, errno := netCommand(dev, "127.0.0.2:80", NetConnect); errno != NetDenied {
+		t.Fatalf("expected address outside allow-list to be denied, got %d", errno)
+	}
+	if _, errno := netCommand(dev, "nope", NetConnect); errno != NetBadAddress {
+		t.Fatalf("expected bad address, got %d", errno)
+	}
+	if _, errno := netCommand(dev, "127.0.0.1:0", NetListen); errno != NetDenied {
+		t.Fatalf("expected listening on other port to be denied, got %d", errno)
+	}
+
+	conn, errno := netCommand(dev, host.Addr().String(), NetConnect)
+	if errno != NetOk {
+		t.Fatalf("unable to connect: %d", errno)
+	}
+	dev.SetUint16(NetSocket, conn)
+	copy(dev.buffer[:], "hai")
+	dev.SetUint16(NetLength, 3)
+	if sent := poll(t, dev, NetSend); sent != 3 {
+		t.Fatalf("expected 3 bytes queued, got %d", sent)
+	}
+	if status, _ := dev.GetUint16(NetStatus); status != NetOpen {
+		t.Fatalf("expected connected socket to be open, got %#x", status)
+	}
+	dev.SetUint16(NetCommand, NetClose)
+
+	select {
+	case msg := <-received:
+		if msg != "hai" {
+			t.Fatalf("expected hai, got %q", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("host did not receive data")
+	}
+
+	dev.SetUint16(NetCommand, NetSend)
+	if errno, _ := dev.GetUint16(NetError); errno != NetBadSocket {
+		t.Fatalf("expected bad socket after close, got %d", errno)
+	}
+	dev.SetUint16(NetCommand, 42)
+	if errno, _ := dev.GetUint16(NetError); errno != NetBadCommand {
+		t.Fatalf("expected bad command, got %d", errno)
+	}
+}
+
+func Test_Net_ConnectRefused(t *testing.T) {
+	host, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	address := host.Addr().String()
+	host.Close()
+
+	dev := NewNet([]string{address}, time.Second)
+	defer dev.Detach()
+	conn, errno := netCommand(dev, address, NetConnect)
+	if errno != NetOk {
+		t.Fatalf("expected connect to be started, got %d", errno)
+	}
+	dev.SetUint16(NetSocket, conn)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if status, _ := dev.GetUint16(NetStatus); status != NetConnecting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if status, _ := dev.GetUint16(NetStatus); status != NetClosed {
+		t.Fatalf("expected refused connection to be closed, got %#x", status)
+	}
+	dev.SetUint16(NetCommand, NetSend)
+	if errno, _ := dev.GetUint16(NetError); errno != NetRefused {
+		t.Fatalf("expected send to be refused, got %d", errno)
+	}
+}
+
+func Test_Net_ConnectDoesNotBlock(t *testing.T) {
+	// Non-routable address either hangs until timeout or fails right away
+	dev := NewNet([]string{"10.255.255.1"}, 5*time.Second)
+	defer dev.Detach()
+
+	started := time.Now()
+	conn, errno := netCommand(dev, "10.255.255.1:80", NetConnect)
+	if errno != NetOk {
+		t.Fatalf("expected connect to be started, got %d", errno)
+	}
+	dev.SetUint16(NetSocket, conn)
+	dev.SetUint16(NetCommand, NetSend)
+	dev.SetUint16(NetCommand, NetRecv)
+	dev.SetUint16(NetCommand, NetClose)
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("expected guest commands not to wait for the network, took %v", elapsed)
+	}
+}
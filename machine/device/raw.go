@@ -0,0 +1,38 @@
+package device
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// enableRawMode switches terminal to raw mode with stty, returning
+// function to restore previous mode. Files that are not terminals
+// are left alone.
+func enableRawMode(f *os.File) (func() error, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return func() error { return nil }, nil
+	}
+	state, err := stty(f, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(f, "raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := stty(f, strings.TrimSpace(state))
+		return err
+	}, nil
+}
+
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,252 @@
+package device
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+	"time"
+)
+
+// Timer channel registers are uint16, at channel*TimerChannelSize offset
+const (
+	TimerControl     memory.Address = 0 // Control flags
+	TimerReload      memory.Address = 2 // Period, in ticks or milliseconds
+	TimerCount       memory.Address = 4 // Remaining until expiry
+	TimerStatus      memory.Address = 6 // Status flags, any write clears them
+	TimerChannelSize memory.Address = 8
+	TimerChannels                   = 2
+)
+
+// Timer control flags
+const (
+	TimerEnable    uint16 = 1 << iota
+	TimerPeriodic  uint16 = 1 << iota
+	TimerIrq       uint16 = 1 << iota
+	TimerWallClock uint16 = 1 << iota // Count milliseconds instead of ticks
+)
+
+// Timer status flags
+const (
+	TimerExpired uint16 = 1 << iota
+)
+
+// RTC registers are uint16, reading current date and time
+const (
+	RtcYear   memory.Address = 0x20
+	RtcMonth  memory.Address = 0x22
+	RtcDay    memory.Address = 0x24
+	RtcHour   memory.Address = 0x26
+	RtcMinute memory.Address = 0x28
+	RtcSecond memory.Address = 0x2a
+)
+
+// Clock is time source for the timer
+type Clock interface {
+	Now() time.Time
+	// Advance is called on each tick with cycles the instruction took
+	Advance(cycles uint64)
+}
+
+// WallClock is host time
+type WallClock struct{}
+
+func (x WallClock) Now() time.Time { return time.Now() }
+func (x WallClock) Advance(uint64) {}
+
+// VirtualClock moves only with executed cycles, for deterministic runs
+type VirtualClock struct {
+	now      time.Time
+	perCycle time.Duration
+	lock     sync.Mutex
+}
+
+func NewVirtualClock(start time.Time, perCycle time.Duration) *VirtualClock {
+	return &VirtualClock{now: start, perCycle: perCycle}
+}
+
+func (x *VirtualClock) Now() time.Time {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.now
+}
+
+func (x *VirtualClock) Advance(cycles uint64) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.now = x.now.Add(time.Duration(cycles) * x.perCycle)
+}
+
+type timerChannel struct {
+	control uint16
+	reload  uint16
+	count   uint16
+	status  uint16
+	last    time.Time
+}
+
+// Timer is programmable interval timer with real-time clock
+type Timer struct {
+	clock    Clock
+	channels [TimerChannels]timerChannel
+	pending  bool
+}
+
+func NewTimer(clock Clock) *Timer {
+	return &Timer{clock: clock}
+}
+
+func (x *Timer) channel(at memory.Address) (*timerChannel, memory.Address, bool) {
+	idx := int(at / TimerChannelSize)
+	if idx >= TimerChannels {
+		return nil, 0, false
+	}
+	return &x.channels[idx], at % TimerChannelSize, true
+}
+
+func (x *Timer) get(reg memory.Address) (uint16, error) {
+	if ch, offset, ok := x.channel(reg); ok {
+		switch offset {
+		case TimerControl:
+			return ch.control, nil
+		case TimerReload:
+			return ch.reload, nil
+		case TimerCount:
+			return ch.count, nil
+		case TimerStatus:
+			return ch.status, nil
+		}
+	}
+	now := x.clock.Now()
+	switch reg {
+	case RtcYear:
+		return uint16(now.Year()), nil
+	case RtcMonth:
+		return uint16(now.Month()), nil
+	case RtcDay:
+		return uint16(now.Day()), nil
+	case RtcHour:
+		return uint16(now.Hour()), nil
+	case RtcMinute:
+		return uint16(now.Minute()), nil
+	case RtcSecond:
+		return uint16(now.Second()), nil
+	}
+	return 0, internal.Error(fmt.Sprintf("unknown timer register %d", reg), nil, internal.ErrorDevice)
+}
+
+func (x *Timer) set(reg memory.Address, value uint16) error {
+	ch, offset, ok := x.channel(reg)
+	if !ok {
+		return internal.Error(fmt.Sprintf("unable to write timer register %d", reg), nil, internal.ErrorDevice)
+	}
+	switch offset {
+	case TimerControl:
+		started := ch.control&TimerEnable == 0 && value&TimerEnable != 0
+		ch.control = value
+		if started {
+			ch.count = ch.reload
+			ch.last = x.clock.Now()
+		}
+	case TimerReload:
+		ch.reload = value
+	case TimerCount:
+		ch.count = value
+	case TimerStatus:
+		ch.status = 0
+	default:
+		return internal.Error(fmt.Sprintf("unable to write timer register %d", reg), nil, internal.ErrorDevice)
+	}
+	return nil
+}
+
+func (x *Timer) GetUint16(at memory.Address) (uint16, error) {
+	return x.get(at)
+}
+
+func (x *Timer) SetUint16(at memory.Address, value uint16) error {
+	return x.set(at, value)
+}
+
+// GetByte reads low or high byte of the register at address
+func (x *Timer) GetByte(at memory.Address) (byte, error) {
+	value, err := x.get(at &^ 1)
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, value)
+	return b[at&1], err
+}
+
+// SetByte writes low or high byte of the register at address
+func (x *Timer) SetByte(at memory.Address, value byte) error {
+	reg := at &^ 1
+	current, err := x.get(reg)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, current)
+	b[at&1] = value
+	return x.set(reg, binary.LittleEndian.Uint16(b))
+}
+
+func (x *Timer) Attach(memory.MemoryType) error {
+	return nil
+}
+
+func (x *Timer) Detach() error {
+	return nil
+}
+
+// Reset stops all channels
+func (x *Timer) Reset() {
+	x.channels = [TimerChannels]timerChannel{}
+	x.pending = false
+}
+
+// Tick counts channels down, by one tick or by elapsed milliseconds
+func (x *Timer) Tick(cycles uint64) error {
+	x.clock.Advance(cycles)
+	now := x.clock.Now()
+	for i := range x.channels {
+		ch := &x.channels[i]
+		if ch.control&TimerEnable == 0 {
+			continue
+		}
+		var elapsed uint64 = 1
+		if ch.control&TimerWallClock != 0 {
+			elapsed = uint64(now.Sub(ch.last) / time.Millisecond)
+			ch.last = ch.last.Add(time.Duration(elapsed) * time.Millisecond)
+		}
+		x.countDown(ch, elapsed)
+	}
+	return nil
+}
+
+func (x *Timer) countDown(ch *timerChannel, elapsed uint64) {
+	for elapsed > 0 && ch.control&TimerEnable != 0 {
+		if uint64(ch.count) > elapsed {
+			ch.count -= uint16(elapsed)
+			return
+		}
+		elapsed -= uint64(ch.count)
+		ch.count = 0
+		ch.status |= TimerExpired
+		if ch.control&TimerIrq != 0 {
+			x.pending = true
+		}
+		if ch.control&TimerPeriodic == 0 || ch.reload == 0 {
+			ch.control &^= TimerEnable
+			return
+		}
+		ch.count = ch.reload
+	}
+}
+
+func (x *Timer) PendingIrq() bool {
+	return x.pending
+}
+
+func (x *Timer) AcknowledgeIrq() {
+	x.pending = false
+}
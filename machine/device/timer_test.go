@@ -0,0 +1,69 @@
+package device
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Timer_Ticks(t *testing.T) {
+	timer := NewTimer(WallClock{})
+	timer.SetUint16(TimerReload, 3)
+	timer.SetUint16(TimerControl, TimerEnable|TimerIrq)
+
+	timer.Tick(1)
+	timer.Tick(1)
+	if count, _ := timer.GetUint16(TimerCount); count != 1 || timer.PendingIrq() {
+		t.Fatalf("expected one tick left, got %d", count)
+	}
+	timer.Tick(1)
+	if status, _ := timer.GetUint16(TimerStatus); status&TimerExpired == 0 || !timer.PendingIrq() {
+		t.Fatalf("expected one-shot timer to expire with IRQ")
+	}
+	if control, _ := timer.GetUint16(TimerControl); control&TimerEnable != 0 {
+		t.Fatalf("expected one-shot timer to stop")
+	}
+	timer.AcknowledgeIrq()
+	timer.SetUint16(TimerStatus, 0)
+	if status, _ := timer.GetUint16(TimerStatus); status != 0 {
+		t.Fatalf("expected status to be cleared, got %d", status)
+	}
+
+	second := TimerChannelSize
+	timer.SetByte(second+TimerReload, 2)
+	timer.SetByte(second+TimerControl, byte(TimerEnable|TimerPeriodic))
+	for i := 0; i < 5; i++ {
+		timer.Tick(1)
+	}
+	if count, _ := timer.GetUint16(second + TimerCount); count != 1 {
+		t.Fatalf("expected periodic timer to reload, got %d", count)
+	}
+	if timer.PendingIrq() {
+		t.Fatalf("expected no IRQ with IRQ disabled")
+	}
+}
+
+func Test_Timer_VirtualClock(t *testing.T) {
+	start := time.Date(2026, 10, 19, 13, 12, 0, 0, time.UTC)
+	timer := NewTimer(NewVirtualClock(start, time.Microsecond))
+	timer.SetUint16(TimerReload, 5)
+	timer.SetUint16(TimerControl, TimerEnable|TimerPeriodic|TimerWallClock|TimerIrq)
+
+	timer.Tick(4500) // 4.5ms
+	if count, _ := timer.GetUint16(TimerCount); count != 1 || timer.PendingIrq() {
+		t.Fatalf("expected 1ms left, got %d", count)
+	}
+	timer.Tick(11500) // 16ms
+	if count, _ := timer.GetUint16(TimerCount); count != 4 || !timer.PendingIrq() {
+		t.Fatalf("expected periodic expiry with 4ms left, got %d", count)
+	}
+
+	if year, _ := timer.GetUint16(RtcYear); year != 2026 {
+		t.Fatalf("expected RTC year, got %d", year)
+	}
+	if minute, _ := timer.GetByte(RtcMinute); minute != 12 {
+		t.Fatalf("expected RTC minute, got %d", minute)
+	}
+	if err := timer.SetUint16(RtcYear, 1); err == nil {
+		t.Fatalf("expected error writing RTC")
+	}
+}
@@ -2,9 +2,14 @@ package device
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
 	"io"
 	"math"
 	"os"
+	"strings"
 	"the-machine/machine/internal"
 	"the-machine/machine/memory"
 )
@@ -18,43 +23,493 @@ const (
 	termEsc string = "\u001B"
 )
 
-type Video struct {
+// Control addresses, past the character grid
+const (
+	VideoCursorX       memory.Address = 0xff00 // Cursor column
+	VideoCursorY       memory.Address = 0xff01 // Cursor row
+	VideoCursorVisible memory.Address = 0xff02 // Non-zero shows cursor
+	VideoClear         memory.Address = 0xff03 // Any write clears the screen
+	VideoScroll        memory.Address = 0xff04 // Write scrolls screen up by that many rows
+	VideoAttribute     memory.Address = 0xff05 // Attribute for byte writes to the grid
+	VideoMode          memory.Address = 0xff06 // Text or pixel mode
+	VideoPaletteIndex  memory.Address = 0xff07 // Palette entry to access through color registers
+	VideoPaletteRed    memory.Address = 0xff08 // Red component of palette entry
+	VideoPaletteGreen  memory.Address = 0xff09 // Green component of palette entry
+	VideoPaletteBlue   memory.Address = 0xff0a // Blue component of palette entry
+)
+
+type Mode byte
+
+const (
+	TextMode  Mode = 0
+	PixelMode Mode = iota
+)
+
+// Attribute is the cell look: foreground color in bits 0-2, background in 3-5,
+// bold in bit 6 and inverse in bit 7. Color 0 is terminal default, 1-7 are
+// ANSI red, green, yellow, blue, magenta, cyan and white.
+type Attribute byte
+
+const (
+	AttrBold    Attribute = 1 << 6
+	AttrInverse Attribute = 1 << 7
+)
+
+func NewAttribute(fg byte, bg byte, flags Attribute) Attribute {
+	return Attribute(fg&7) | Attribute(bg&7)<<3 | flags&(AttrBold|AttrInverse)
+}
+
+func (x Attribute) Foreground() byte { return byte(x) & 7 }
+func (x Attribute) Background() byte { return byte(x>>3) & 7 }
+func (x Attribute) Bold() bool       { return x&AttrBold != 0 }
+func (x Attribute) Inverse() bool    { return x&AttrInverse != 0 }
+
+// SGR renders attribute as ANSI select graphic rendition sequence,
+// empty for default look
+func (x Attribute) SGR() string {
+	if x == 0 {
+		return ""
+	}
+	params := []string{"0"}
+	if x.Bold() {
+		params = append(params, "1")
+	}
+	if x.Inverse() {
+		params = append(params, "7")
+	}
+	if fg := x.Foreground(); fg != 0 {
+		params = append(params, fmt.Sprintf("%d", 30+fg))
+	}
+	if bg := x.Background(); bg != 0 {
+		params = append(params, fmt.Sprintf("%d", 40+bg))
+	}
+	return termEsc + "[" + strings.Join(params, ";") + "m"
+}
+
+type Cell struct {
+	Char byte
+	Attr Attribute
+}
+
+// Presenter shows screen changes to the outside world
+type Presenter interface {
+	Present(x uint8, y uint8, cell Cell) error
+	Cursor(x uint8, y uint8, visible bool) error
+	Clear() error
+}
+
+// TerminalPresenter draws each change in terminal, with ANSI escapes
+type TerminalPresenter struct {
 	stream io.Writer
 }
 
+func NewTerminalPresenter(stream io.Writer) TerminalPresenter {
+	return TerminalPresenter{stream: stream}
+}
+
+func (x TerminalPresenter) Present(col uint8, row uint8, cell Cell) error {
+	if sgr := cell.Attr.SGR(); sgr != "" {
+		_, err := fmt.Fprintf(x.stream, "%s[%d;%dH%s%c%s[0m", termEsc, row, col, sgr, cell.Char, termEsc)
+		return err
+	}
+	_, err := fmt.Fprintf(x.stream, "%s[%d;%dH%c", termEsc, row, col, cell.Char)
+	return err
+}
+
+func (x TerminalPresenter) Cursor(col uint8, row uint8, visible bool) error {
+	if !visible {
+		_, err := fmt.Fprintf(x.stream, "%s[?25l", termEsc)
+		return err
+	}
+	_, err := fmt.Fprintf(x.stream, "%s[%d;%dH%s[?25h", termEsc, row, col, termEsc)
+	return err
+}
+
+func (x TerminalPresenter) Clear() error {
+	_, err := fmt.Fprintf(x.stream, "%s[2J", termEsc)
+	return err
+}
+
+// PixelPresenter is implemented by presenters able to show pixel mode
+type PixelPresenter interface {
+	PresentPixel(x uint8, y uint8, c color.RGBA) error
+}
+
+// PresentPixel draws pixel as a space with true color background
+func (x TerminalPresenter) PresentPixel(col uint8, row uint8, c color.RGBA) error {
+	_, err := fmt.Fprintf(x.stream, "%s[%d;%dH%s[48;2;%d;%d;%dm %s[0m", termEsc, row, col, termEsc, c.R, c.G, c.B, termEsc)
+	return err
+}
+
+// Headless keeps screen in memory only
+type Headless struct{}
+
+func (x Headless) Present(uint8, uint8, Cell) error { return nil }
+func (x Headless) Cursor(uint8, uint8, bool) error  { return nil }
+func (x Headless) Clear() error                     { return nil }
+
+// graphics is pixel mode state
+type graphics struct {
+	mode    Mode
+	width   int
+	height  int
+	pixels  []byte
+	palette color.Palette
+	index   byte
+}
+
+// DefaultPalette has 16 CGA colors, followed by black
+func DefaultPalette() color.Palette {
+	cga := []color.RGBA{
+		{0x00, 0x00, 0x00, 0xff}, {0x00, 0x00, 0xaa, 0xff}, {0x00, 0xaa, 0x00, 0xff}, {0x00, 0xaa, 0xaa, 0xff},
+		{0xaa, 0x00, 0x00, 0xff}, {0xaa, 0x00, 0xaa, 0xff}, {0xaa, 0x55, 0x00, 0xff}, {0xaa, 0xaa, 0xaa, 0xff},
+		{0x55, 0x55, 0x55, 0xff}, {0x55, 0x55, 0xff, 0xff}, {0x55, 0xff, 0x55, 0xff}, {0x55, 0xff, 0xff, 0xff},
+		{0xff, 0x55, 0x55, 0xff}, {0xff, 0x55, 0xff, 0xff}, {0xff, 0xff, 0x55, 0xff}, {0xff, 0xff, 0xff, 0xff},
+	}
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		if i < len(cga) {
+			palette[i] = cga[i]
+		} else {
+			palette[i] = color.RGBA{0, 0, 0, 0xff}
+		}
+	}
+	return palette
+}
+
+type cursor struct {
+	x       uint8
+	y       uint8
+	visible bool
+}
+
+// Video is a character screen, backed by in-memory buffer
+type Video struct {
+	buffer     []byte
+	attributes []Attribute
+	cursor     *cursor
+	pen        *Attribute
+	graphics   *graphics
+	presenter  Presenter
+}
+
 func NewVideo() memory.MemoryAccess {
-	return Video{stream: os.Stdout}
+	return NewVideoWithPresenter(NewTerminalPresenter(os.Stdout))
+}
+
+func NewHeadlessVideo() memory.MemoryAccess {
+	return NewVideoWithPresenter(Headless{})
+}
+
+func NewVideoWithPresenter(presenter Presenter) Video {
+	size := int(screenWidth) * int(screenHeight)
+	var pen Attribute
+	return Video{
+		buffer:     make([]byte, size),
+		attributes: make([]Attribute, size),
+		cursor:     &cursor{},
+		pen:        &pen,
+		graphics:   &graphics{width: 128, height: 128, pixels: make([]byte, 128*128), palette: DefaultPalette()},
+		presenter:  presenter,
+	}
+}
+
+// SetResolution sets pixel mode size, clearing the pixels.
+// Pixels have to fit below control addresses.
+func (x Video) SetResolution(width int, height int) error {
+	if width <= 0 || height <= 0 || width > int(screenWidth) || height > int(screenHeight) {
+		return internal.Error(fmt.Sprintf("unsupported resolution %dx%d", width, height), nil, internal.ErrorMemory)
+	}
+	x.graphics.width = width
+	x.graphics.height = height
+	x.graphics.pixels = make([]byte, width*height)
+	return nil
 }
 
-func (x Video) GetByte(at memory.Address) (byte, error)     { return 0, nil }
-func (x Video) GetUint16(at memory.Address) (uint16, error) { return 0, nil }
+func (x Video) GetMode() Mode {
+	return x.graphics.mode
+}
 
+func (x Video) getPixel(at memory.Address) (byte, error) {
+	if int(at) >= len(x.graphics.pixels) {
+		return 0, internal.Error(fmt.Sprintf("pixel %d outside %dx%d", at, x.graphics.width, x.graphics.height), nil, internal.ErrorMemory)
+	}
+	return x.graphics.pixels[at], nil
+}
+
+func (x Video) setPixel(at memory.Address, index byte) error {
+	if int(at) >= len(x.graphics.pixels) {
+		return internal.Error(fmt.Sprintf("pixel %d outside %dx%d", at, x.graphics.width, x.graphics.height), nil, internal.ErrorMemory)
+	}
+	x.graphics.pixels[at] = index
+	if presenter, ok := x.presenter.(PixelPresenter); ok {
+		col, row := uint8(int(at)%x.graphics.width), uint8(int(at)/x.graphics.width)
+		if err := presenter.PresentPixel(col, row, x.graphics.palette[index].(color.RGBA)); err != nil {
+			return internal.Error(fmt.Sprintf("unable to present pixel %d", at), err, internal.ErrorMemory)
+		}
+	}
+	return nil
+}
+
+// Frame returns copy of pixel mode image
+func (x Video) Frame() *image.Paletted {
+	frame := image.NewPaletted(image.Rect(0, 0, x.graphics.width, x.graphics.height), append(color.Palette{}, x.graphics.palette...))
+	copy(frame.Pix, x.graphics.pixels)
+	return frame
+}
+
+// WritePNG exports current frame as PNG
+func (x Video) WritePNG(w io.Writer) error {
+	if err := png.Encode(w, x.Frame()); err != nil {
+		return internal.Error("unable to export PNG", err, internal.ErrorSaving)
+	}
+	return nil
+}
+
+// AppendFrame adds current frame to animation, delay in 100ths of a second
+func (x Video) AppendFrame(animation *gif.GIF, delay int) {
+	animation.Image = append(animation.Image, x.Frame())
+	animation.Delay = append(animation.Delay, delay)
+}
+
+// WriteGIF exports animation
+func WriteGIF(w io.Writer, animation *gif.GIF) error {
+	if err := gif.EncodeAll(w, animation); err != nil {
+		return internal.Error("unable to export GIF", err, internal.ErrorSaving)
+	}
+	return nil
+}
+
+func (x Video) GetByte(at memory.Address) (byte, error) {
+	if at >= VideoCursorX {
+		return x.getControl(at)
+	}
+	if x.graphics.mode == PixelMode {
+		return x.getPixel(at)
+	}
+	if _, err := x.addressToCoords(at); err != nil {
+		return 0, internal.Error(fmt.Sprintf("unable to read screen at %v", at), err, internal.ErrorMemory)
+	}
+	return x.buffer[at], nil
+}
+
+// GetUint16 reads cell as attribute in the high byte and character in the low
+func (x Video) GetUint16(at memory.Address) (uint16, error) {
+	b, err := x.GetByte(at)
+	if err != nil || at >= VideoCursorX || x.graphics.mode == PixelMode {
+		return uint16(b), err
+	}
+	return uint16(x.attributes[at])<<8 | uint16(b), nil
+}
+
+// SetUint16 writes cell, with attribute in the high byte and character in the low
 func (x Video) SetUint16(at memory.Address, val uint16) error {
-	return x.SetByte(at, byte(val))
+	if at >= VideoCursorX {
+		return x.setControl(at, byte(val))
+	}
+	if x.graphics.mode == PixelMode {
+		return x.setPixel(at, byte(val))
+	}
+	return x.draw(at, Cell{Char: byte(val), Attr: Attribute(val >> 8)})
 }
 
+// SetByte writes character, using current attribute
 func (x Video) SetByte(at memory.Address, val byte) error {
+	if at >= VideoCursorX {
+		return x.setControl(at, val)
+	}
+	if x.graphics.mode == PixelMode {
+		return x.setPixel(at, val)
+	}
+	return x.draw(at, Cell{Char: val, Attr: *x.pen})
+}
+
+func (x Video) draw(at memory.Address, cell Cell) error {
 	coords, err := x.addressToCoords(at)
 	if err != nil {
-		return internal.Error(fmt.Sprintf("unable to print output %c at %v", val, at), err, internal.ErrorMemory)
+		return internal.Error(fmt.Sprintf("unable to print output %c at %v", cell.Char, at), err, internal.ErrorMemory)
+	}
+	x.buffer[at] = cell.Char
+	x.attributes[at] = cell.Attr
+	if err := x.presenter.Present(coords[0], coords[1], cell); err != nil {
+		return internal.Error(fmt.Sprintf("unable to present output %c at %v", cell.Char, at), err, internal.ErrorMemory)
+	}
+	if x.cursor.visible {
+		return x.presentCursor()
+	}
+	return nil
+}
+
+func (x Video) getControl(at memory.Address) (byte, error) {
+	switch at {
+	case VideoCursorX:
+		return x.cursor.x, nil
+	case VideoCursorY:
+		return x.cursor.y, nil
+	case VideoCursorVisible:
+		if x.cursor.visible {
+			return 1, nil
+		}
+		return 0, nil
+	case VideoAttribute:
+		return byte(*x.pen), nil
+	case VideoMode:
+		return byte(x.graphics.mode), nil
+	case VideoPaletteIndex:
+		return x.graphics.index, nil
+	case VideoPaletteRed:
+		return x.graphics.palette[x.graphics.index].(color.RGBA).R, nil
+	case VideoPaletteGreen:
+		return x.graphics.palette[x.graphics.index].(color.RGBA).G, nil
+	case VideoPaletteBlue:
+		return x.graphics.palette[x.graphics.index].(color.RGBA).B, nil
+	case VideoClear, VideoScroll:
+		return 0, nil
+	}
+	return 0, internal.Error(fmt.Sprintf("unknown video control address %#04x", at), nil, internal.ErrorMemory)
+}
+
+func (x Video) setControl(at memory.Address, val byte) error {
+	switch at {
+	case VideoCursorX:
+		x.cursor.x = val % screenWidth
+		return x.presentCursor()
+	case VideoCursorY:
+		x.cursor.y = val % screenHeight
+		return x.presentCursor()
+	case VideoCursorVisible:
+		x.cursor.visible = val != 0
+		return x.presentCursor()
+	case VideoAttribute:
+		*x.pen = Attribute(val)
+		return nil
+	case VideoMode:
+		if Mode(val) != TextMode && Mode(val) != PixelMode {
+			return internal.Error(fmt.Sprintf("unknown video mode %d", val), nil, internal.ErrorMemory)
+		}
+		x.graphics.mode = Mode(val)
+		return x.Clear()
+	case VideoPaletteIndex:
+		x.graphics.index = val
+		return nil
+	case VideoPaletteRed, VideoPaletteGreen, VideoPaletteBlue:
+		c := x.graphics.palette[x.graphics.index].(color.RGBA)
+		switch at {
+		case VideoPaletteRed:
+			c.R = val
+		case VideoPaletteGreen:
+			c.G = val
+		case VideoPaletteBlue:
+			c.B = val
+		}
+		x.graphics.palette[x.graphics.index] = c
+		return nil
+	case VideoClear:
+		return x.Clear()
+	case VideoScroll:
+		return x.Scroll(int(val))
+	}
+	return internal.Error(fmt.Sprintf("unknown video control address %#04x", at), nil, internal.ErrorMemory)
+}
+
+func (x Video) presentCursor() error {
+	if err := x.presenter.Cursor(x.cursor.x, x.cursor.y, x.cursor.visible); err != nil {
+		return internal.Error("unable to present cursor", err, internal.ErrorMemory)
 	}
-	fmt.Fprintf(x.stream,
-		fmt.Sprintf("%s[%d;%dH%c", termEsc, coords[1], coords[0], val))
 	return nil
 }
 
+// Clear blanks the whole screen, text and pixels
+func (x Video) Clear() error {
+	for i := range x.buffer {
+		x.buffer[i] = 0
+		x.attributes[i] = 0
+	}
+	for i := range x.graphics.pixels {
+		x.graphics.pixels[i] = 0
+	}
+	if err := x.presenter.Clear(); err != nil {
+		return internal.Error("unable to clear screen", err, internal.ErrorMemory)
+	}
+	return x.presentCursor()
+}
+
+// Scroll moves text screen contents up by rows, blanking rows at the bottom
+func (x Video) Scroll(rows int) error {
+	if rows <= 0 {
+		return nil
+	}
+	if rows > int(screenHeight) {
+		rows = int(screenHeight)
+	}
+	shift := rows * int(screenWidth)
+	copy(x.buffer, x.buffer[shift:])
+	copy(x.attributes, x.attributes[shift:])
+	for i := len(x.buffer) - shift; i < len(x.buffer); i++ {
+		x.buffer[i] = 0
+		x.attributes[i] = 0
+	}
+	return x.redraw()
+}
+
+func (x Video) redraw() error {
+	if err := x.presenter.Clear(); err != nil {
+		return internal.Error("unable to redraw screen", err, internal.ErrorMemory)
+	}
+	for at, char := range x.buffer {
+		if char == 0 {
+			continue
+		}
+		col, row := uint8(at%int(screenWidth)), uint8(at/int(screenWidth))
+		if err := x.presenter.Present(col, row, Cell{Char: char, Attr: x.attributes[at]}); err != nil {
+			return internal.Error("unable to redraw screen", err, internal.ErrorMemory)
+		}
+	}
+	return x.presentCursor()
+}
+
+// Cell returns character and attribute at screen position
+func (x Video) Cell(col uint8, row uint8) Cell {
+	if col >= screenWidth || row >= screenHeight {
+		return Cell{}
+	}
+	at := int(row)*int(screenWidth) + int(col)
+	return Cell{Char: x.buffer[at], Attr: x.attributes[at]}
+}
+
+// Lines returns screen contents as text, with trailing blanks trimmed
+func (x Video) Lines() []string {
+	lines := make([]string, 0, screenHeight)
+	last := 0
+	for row := 0; row < int(screenHeight); row++ {
+		from := row * int(screenWidth)
+		line := make([]byte, screenWidth)
+		for col, char := range x.buffer[from : from+int(screenWidth)] {
+			if char < ' ' || char > '~' {
+				char = ' '
+			}
+			line[col] = char
+		}
+		lines = append(lines, strings.TrimRight(string(line), " "))
+		if lines[row] != "" {
+			last = row + 1
+		}
+	}
+	return lines[:last]
+}
+
 func (v Video) addressToCoords(at memory.Address) ([]uint8, error) {
 	coords := make([]uint8, 2, 2)
 
 	x := uint16(at) % uint16(screenWidth)
-	if x > uint16(screenWidth) {
+	if x >= uint16(screenWidth) {
 		return coords, internal.Error(fmt.Sprintf("X outside bounds (%d): %d", screenWidth, x), nil, internal.ErrorMemory)
 	}
 	coords[0] = uint8(x)
 
 	y := uint16(math.Floor(float64(at) / float64(screenWidth)))
-	if y > uint16(screenWidth) {
-		return coords, internal.Error(fmt.Sprintf("X outside bounds (%d): %d", screenWidth, y), nil, internal.ErrorMemory)
+	if y >= uint16(screenHeight) {
+		return coords, internal.Error(fmt.Sprintf("Y outside bounds (%d): %d", screenHeight, y), nil, internal.ErrorMemory)
 	}
 	coords[1] = uint8(y)
 
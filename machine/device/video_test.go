@@ -2,6 +2,9 @@ package device
 
 import (
 	"bytes"
+	"image/color"
+	"image/gif"
+	"image/png"
 	"testing"
 	"the-machine/machine/memory"
 )
@@ -46,7 +49,7 @@ func Test_AddressToCoords(t *testing.T) {
 
 func Test_SetByte_DrawsChar(t *testing.T) {
 	var output bytes.Buffer
-	vga := Video{stream: &output}
+	vga := NewVideoWithPresenter(NewTerminalPresenter(&output))
 
 	if err := vga.SetByte(1312, 65); err != nil {
 		t.Fatalf("error rendering byte: %v", err)
@@ -55,3 +58,146 @@ func Test_SetByte_DrawsChar(t *testing.T) {
 		t.Fatalf("unexpected output rendered: %s", output.String())
 	}
 }
+
+func Test_Video_Framebuffer(t *testing.T) {
+	vga := NewHeadlessVideo().(Video)
+
+	for i, char := range []byte("hai") {
+		if err := vga.SetByte(memory.Address(255*2+10+i), char); err != nil {
+			t.Fatalf("error writing to screen: %v", err)
+		}
+	}
+	if b, err := vga.GetByte(255*2 + 11); err != nil || b != 'a' {
+		t.Fatalf("expected to read back screen contents, got %c and error %v", b, err)
+	}
+	if _, err := vga.GetByte(255 * 255); err == nil {
+		t.Fatalf("expected error reading outside screen")
+	}
+
+	lines := vga.Lines()
+	if len(lines) != 3 || lines[0] != "" || lines[2] != "          hai" {
+		t.Fatalf("unexpected screen lines: %q", lines)
+	}
+}
+
+func Test_Video_Attributes(t *testing.T) {
+	attr := NewAttribute(1, 4, AttrBold)
+	if attr.SGR() != "\u001B[0;1;31;44m" {
+		t.Fatalf("unexpected SGR for bold red on blue: %q", attr.SGR())
+	}
+	if Attribute(0).SGR() != "" {
+		t.Fatalf("expected no SGR for default attribute")
+	}
+
+	var output bytes.Buffer
+	vga := NewVideoWithPresenter(NewTerminalPresenter(&output))
+	if err := vga.SetUint16(0, uint16(NewAttribute(2, 0, AttrInverse))<<8|'X'); err != nil {
+		t.Fatalf("error writing cell: %v", err)
+	}
+	if output.String() != "\u001B[0;0H\u001B[0;7;32mX\u001B[0m" {
+		t.Fatalf("unexpected output rendered: %q", output.String())
+	}
+	if cell, err := vga.GetUint16(0); err != nil || cell != uint16(NewAttribute(2, 0, AttrInverse))<<8|'X' {
+		t.Fatalf("expected to read back cell with attribute, got %#04x and error %v", cell, err)
+	}
+
+	vga.SetByte(VideoAttribute, byte(NewAttribute(3, 0, 0)))
+	vga.SetByte(1, 'Y')
+	if cell := vga.Cell(1, 0); cell.Char != 'Y' || cell.Attr != NewAttribute(3, 0, 0) {
+		t.Fatalf("expected byte write to use current attribute, got %v", cell)
+	}
+}
+
+func Test_Video_Controls(t *testing.T) {
+	var output bytes.Buffer
+	vga := NewVideoWithPresenter(NewTerminalPresenter(&output))
+
+	vga.SetByte(VideoCursorX, 12)
+	vga.SetByte(VideoCursorY, 13)
+	output.Reset()
+	vga.SetByte(VideoCursorVisible, 1)
+	if output.String() != "\u001B[13;12H\u001B[?25h" {
+		t.Fatalf("unexpected cursor output: %q", output.String())
+	}
+	if y, _ := vga.GetByte(VideoCursorY); y != 13 {
+		t.Fatalf("expected to read back cursor row, got %d", y)
+	}
+
+	vga.SetByte(255*0+3, 'a')
+	vga.SetByte(255*1+3, 'b')
+	vga.SetByte(VideoScroll, 1)
+	if lines := vga.Lines(); len(lines) != 1 || lines[0] != "   b" {
+		t.Fatalf("expected screen to scroll up, got %q", lines)
+	}
+
+	vga.SetByte(VideoClear, 1)
+	if lines := vga.Lines(); len(lines) != 0 {
+		t.Fatalf("expected screen to be cleared, got %q", lines)
+	}
+
+	if err := vga.SetByte(0xff10, 1); err == nil {
+		t.Fatalf("expected error writing unknown control address")
+	}
+}
+
+func Test_Video_PixelMode(t *testing.T) {
+	vga := NewHeadlessVideo().(Video)
+	if err := vga.SetResolution(4, 2); err != nil {
+		t.Fatalf("unable to set resolution: %v", err)
+	}
+	if err := vga.SetResolution(256, 2); err == nil {
+		t.Fatalf("expected error setting resolution wider than screen")
+	}
+
+	vga.SetByte(VideoMode, byte(PixelMode))
+	vga.SetByte(VideoPaletteIndex, 20)
+	vga.SetByte(VideoPaletteRed, 0x12)
+	vga.SetByte(VideoPaletteGreen, 0x34)
+	vga.SetByte(VideoPaletteBlue, 0x56)
+	if g, _ := vga.GetByte(VideoPaletteGreen); g != 0x34 {
+		t.Fatalf("expected to read back palette register, got %#02x", g)
+	}
+
+	vga.SetByte(1, 20)
+	vga.SetByte(4*1+3, 15)
+	if px, err := vga.GetByte(1); err != nil || px != 20 {
+		t.Fatalf("expected to read back pixel, got %d and error %v", px, err)
+	}
+	if err := vga.SetByte(8, 1); err == nil {
+		t.Fatalf("expected error writing outside resolution")
+	}
+
+	var out bytes.Buffer
+	if err := vga.WritePNG(&out); err != nil {
+		t.Fatalf("unable to export PNG: %v", err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatalf("unable to decode exported PNG: %v", err)
+	}
+	if img.Bounds().Dx() != 4 || img.Bounds().Dy() != 2 {
+		t.Fatalf("unexpected image size: %v", img.Bounds())
+	}
+	if r, g, b, _ := img.At(1, 0).RGBA(); r>>8 != 0x12 || g>>8 != 0x34 || b>>8 != 0x56 {
+		t.Fatalf("expected palette color at pixel, got %x %x %x", r>>8, g>>8, b>>8)
+	}
+	if c := color.RGBAModel.Convert(img.At(3, 1)).(color.RGBA); c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Fatalf("expected white at pixel, got %v", c)
+	}
+
+	var animation gif.GIF
+	vga.AppendFrame(&animation, 10)
+	vga.SetByte(0, 4)
+	vga.AppendFrame(&animation, 10)
+	out.Reset()
+	if err := WriteGIF(&out, &animation); err != nil {
+		t.Fatalf("unable to export GIF: %v", err)
+	}
+	decoded, err := gif.DecodeAll(&out)
+	if err != nil || len(decoded.Image) != 2 {
+		t.Fatalf("expected 2 frame animation, got error %v", err)
+	}
+	if decoded.Image[0].ColorIndexAt(0, 0) != 0 || decoded.Image[1].ColorIndexAt(0, 0) != 4 {
+		t.Fatalf("expected frames to be captured independently")
+	}
+}
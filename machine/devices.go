@@ -0,0 +1,123 @@
+package machine
+
+import (
+	"fmt"
+	"sort"
+	"the-machine/machine/cpu"
+	"the-machine/machine/device"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+)
+
+type attachedDevice struct {
+	kind   memory.MemoryType
+	device device.Device
+}
+
+// deviceSet holds devices with lifecycle, in memory type order
+type deviceSet struct {
+	attached []attachedDevice
+}
+
+// AttachDevice maps device as memory type, to be ticked and reset along with the machine
+func (vm *Machine) AttachDevice(kind memory.MemoryType, dev device.Device) error {
+	if _, ok := vm.memory[kind]; ok {
+		return internal.Error(fmt.Sprintf("memory %s already attached", kind), nil, internal.ErrorDevice)
+	}
+	if err := dev.Attach(kind); err != nil {
+		return internal.Error(fmt.Sprintf("unable to attach device as %s", kind), err, internal.ErrorDevice)
+	}
+	vm.memory[kind] = dev
+	vm.devices.attached = append(vm.devices.attached, attachedDevice{kind: kind, device: dev})
+	sort.Slice(vm.devices.attached, func(i, j int) bool {
+		return vm.devices.attached[i].kind < vm.devices.attached[j].kind
+	})
+	return nil
+}
+
+// AttachRegistered creates registered device by name and attaches it
+func (vm *Machine) AttachRegistered(name string, params device.Params) (device.Device, error) {
+	kind, dev, err := device.Create(name, params)
+	if err != nil {
+		return dev, err
+	}
+	if err := vm.AttachDevice(kind, dev); err != nil {
+		return dev, err
+	}
+	return dev, nil
+}
+
+func (vm *Machine) DetachDevice(kind memory.MemoryType) error {
+	for idx, d := range vm.devices.attached {
+		if d.kind != kind {
+			continue
+		}
+		vm.devices.attached = append(vm.devices.attached[:idx], vm.devices.attached[idx+1:]...)
+		delete(vm.memory, kind)
+		if err := d.device.Detach(); err != nil {
+			return internal.Error(fmt.Sprintf("unable to detach %s device", kind), err, internal.ErrorDevice)
+		}
+		return nil
+	}
+	return internal.Error(fmt.Sprintf("no device attached as %s", kind), nil, internal.ErrorDevice)
+}
+
+// DetachDevices detaches all devices, e.g. on shutdown
+func (vm *Machine) DetachDevices() error {
+	var first error
+	for len(vm.devices.attached) > 0 {
+		if err := vm.DetachDevice(vm.devices.attached[0].kind); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (vm *Machine) resetDevices() {
+	for _, d := range vm.devices.attached {
+		d.device.Reset()
+	}
+}
+
+// tickDevices holds memory lock, as other cores may access the devices meanwhile
+func (vm *Machine) tickDevices(cycles uint64) error {
+	vm.memoryLock.Lock()
+	defer vm.memoryLock.Unlock()
+	for _, d := range vm.devices.attached {
+		if err := d.device.Tick(cycles); err != nil {
+			return internal.Error(fmt.Sprintf("%s device tick failed", d.kind), err, internal.ErrorDevice)
+		}
+	}
+	return nil
+}
+
+// interrupt enters IRQ trap handler for the first device with pending IRQ.
+// IRQs stay pending while in a trap, or if there's no handler.
+func (vm *Machine) interrupt() error {
+	if vm.IsDone() || vm.cpu.InTrap() {
+		return nil
+	}
+	handler, ok := vm.traps[cpu.TrapIrq]
+	if !ok {
+		return nil
+	}
+	vm.memoryLock.Lock()
+	defer vm.memoryLock.Unlock()
+	for _, d := range vm.devices.attached {
+		irq, ok := d.device.(device.Interrupter)
+		if !ok || !irq.PendingIrq() {
+			continue
+		}
+		if err := vm.cpu.EnterTrap(cpu.TrapIrq, uint16(d.kind), uint16(handler)); err != nil {
+			return internal.Error(fmt.Sprintf("unable to handle %s IRQ", d.kind), err, internal.ErrorDevice)
+		}
+		irq.AcknowledgeIrq()
+		return nil
+	}
+	return nil
+}
+
+// AttachDevice attaches device to shared memory, ticked by and interrupting core 0
+func (vm *MultiCore) AttachDevice(kind memory.MemoryType, dev device.Device) error {
+	return vm.cores[0].AttachDevice(kind, dev)
+}
@@ -0,0 +1,188 @@
+package machine
+
+import (
+	"testing"
+	"the-machine/machine/cpu"
+	"the-machine/machine/device"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+	"time"
+)
+
+// countdown raises IRQ once it has seen enough cycles
+type countdown struct {
+	memory.MemoryAccess
+	left     uint64
+	pending  bool
+	attached memory.MemoryType
+	resets   int
+	detached bool
+}
+
+func (x *countdown) Attach(kind memory.MemoryType) error {
+	x.attached = kind
+	return nil
+}
+func (x *countdown) Reset()        { x.resets++ }
+func (x *countdown) Detach() error { x.detached = true; return nil }
+func (x *countdown) Tick(cycles uint64) error {
+	if x.left == 0 {
+		return nil
+	}
+	if cycles >= x.left {
+		x.left = 0
+		x.pending = true
+		return nil
+	}
+	x.left -= cycles
+	return nil
+}
+func (x *countdown) PendingIrq() bool { return x.pending }
+func (x *countdown) AcknowledgeIrq()  { x.pending = false }
+
+func Test_Devices_Irq(t *testing.T) {
+	vm := NewMachine(255)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_R3.Pack(1),
+		instruction.MOV_LIT_R3.Pack(2),
+		instruction.MOV_LIT_R3.Pack(3),
+		instruction.MOV_LIT_R3.Pack(4),
+	))
+	vm.LoadProgram(200, packStatements(instruction.SYSRET,
+		instruction.MOV_REG_REG.Pack(register.R1.AsUint16(), register.R5.AsUint16()),
+		instruction.MOV_REG_REG.Pack(register.R2.AsUint16(), register.R6.AsUint16()),
+		instruction.MOV_REG_REG.Pack(register.R3.AsUint16(), register.R7.AsUint16()),
+	))
+	vm.SetTrapHandler(cpu.TrapIrq, 200)
+
+	kind := memory.DeviceCustom + 1
+	dev := &countdown{MemoryAccess: memory.NewMemory(4), left: 2}
+	if err := vm.AttachDevice(kind, dev); err != nil {
+		t.Fatalf("unable to attach device: %v", err)
+	}
+	if dev.attached != kind {
+		t.Fatalf("expected device to be told its memory type, got %v", dev.attached)
+	}
+	if err := vm.AttachDevice(memory.RAM, &countdown{}); err == nil {
+		t.Fatalf("expected error attaching device over existing memory")
+	}
+
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	if vm.cpu.GetRegister(register.R5) != uint16(cpu.TrapIrq) || vm.cpu.GetRegister(register.R6) != uint16(kind) {
+		t.Fatalf("expected IRQ trap from device, got trap %d from %d", vm.cpu.GetRegister(register.R5), vm.cpu.GetRegister(register.R6))
+	}
+	if vm.cpu.GetRegister(register.R7) != 2 {
+		t.Fatalf("expected IRQ after second instruction, got %d", vm.cpu.GetRegister(register.R7))
+	}
+	if vm.cpu.GetRegister(register.R3) != 4 {
+		t.Fatalf("expected program to resume after IRQ")
+	}
+
+	vm.Reset()
+	if dev.resets != 1 {
+		t.Fatalf("expected device reset with machine")
+	}
+	if err := vm.DetachDevices(); err != nil || !dev.detached {
+		t.Fatalf("expected device to be detached, got %v", err)
+	}
+	if _, err := vm.GetBank(kind); err == nil {
+		t.Fatalf("expected detached device memory to be gone")
+	}
+}
+
+func Test_Devices_Registry(t *testing.T) {
+	kind := memory.DeviceCustom + 2
+	err := device.Register("countdown", kind, func(params device.Params) (device.Device, error) {
+		left, err := params.Int("cycles", 10)
+		return &countdown{MemoryAccess: memory.NewMemory(4), left: uint64(left)}, err
+	})
+	if err != nil {
+		t.Fatalf("unable to register device: %v", err)
+	}
+
+	vm := NewMachine(255)
+	dev, err := vm.AttachRegistered("countdown", device.Params{"cycles": "3"})
+	if err != nil {
+		t.Fatalf("unable to attach registered device: %v", err)
+	}
+	if dev.(*countdown).left != 3 {
+		t.Fatalf("expected device params to be passed on")
+	}
+	if mem, _ := vm.GetBank(kind); mem != dev {
+		t.Fatalf("expected registered device at its memory type")
+	}
+}
+
+func Test_Devices_TimerIrq(t *testing.T) {
+	vm := NewMachine(255)
+	if _, err := vm.AttachRegistered("timer", device.Params{"clock": "virtual"}); err != nil {
+		t.Fatalf("unable to attach timer: %v", err)
+	}
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_BNK.Pack(uint16(memory.DeviceTimer)),
+		instruction.MOV_LIT_AC.Pack(uint16(device.TimerReload)),
+		instruction.MOV_LIT_MEM.Pack(4),
+		instruction.MOV_LIT_AC.Pack(uint16(device.TimerControl)),
+		instruction.MOV_LIT_MEM.Pack(uint16(device.TimerEnable|device.TimerPeriodic|device.TimerIrq)),
+		instruction.NOP.Pack(),
+		instruction.NOP.Pack(),
+		instruction.NOP.Pack(),
+		instruction.NOP.Pack(),
+		instruction.NOP.Pack(),
+		instruction.NOP.Pack(),
+	))
+	vm.LoadProgram(200, packStatements(instruction.SYSRET,
+		instruction.ADD_REG_LIT.Pack(register.R8.AsUint16(), 1),
+		instruction.MOV_REG_REG.Pack(register.Ac.AsUint16(), register.R8.AsUint16()),
+	))
+	vm.SetTrapHandler(cpu.TrapIrq, 200)
+
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	// Timer counts every tick, handler ones included
+	if vm.cpu.GetRegister(register.R8) != 4 {
+		t.Fatalf("expected periodic timer IRQs while running, got %d", vm.cpu.GetRegister(register.R8))
+	}
+}
+
+func Test_Devices_MultiCoreParallel(t *testing.T) {
+	vm := NewMultiCore(2, 255, Parallel)
+	timer := device.NewTimer(device.NewVirtualClock(time.Unix(0, 0), time.Microsecond))
+	if err := vm.AttachDevice(memory.DeviceTimer, timer); err != nil {
+		t.Fatalf("unable to attach timer: %v", err)
+	}
+	timer.SetUint16(device.TimerReload, 3)
+	timer.SetUint16(device.TimerControl, device.TimerEnable|device.TimerPeriodic)
+
+	// Core 1 keeps reading the timer core 0 is ticking
+	worker := packProgram(
+		instruction.MOV_LIT_BNK.Pack(uint16(memory.DeviceTimer)),
+		instruction.MOV_LIT_R3.Pack(1000),
+		instruction.MOV_LIT_R4.Pack(106),
+		instruction.MOV_LIT_AC.Pack(uint16(device.TimerCount)),
+		instruction.MOV_MEM_REG.Pack(register.Ac.AsUint16(), register.R1.AsUint16()),
+		instruction.ADD_REG_LIT.Pack(register.R5.AsUint16(), 1),
+		instruction.MOV_REG_REG.Pack(register.Ac.AsUint16(), register.R5.AsUint16()),
+		instruction.JLT.Pack(register.R3.AsUint16(), register.R4.AsUint16()),
+	)
+	main := packProgram(
+		instruction.MOV_LIT_R1.Pack(1),
+		instruction.MOV_LIT_R2.Pack(100),
+		instruction.START_CORE.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+		instruction.WAIT_CORE.Pack(register.R1.AsUint16()),
+	)
+	vm.LoadProgram(100, worker)
+	vm.LoadProgram(0, main)
+
+	if steps, err := vm.Run(0xffff); err != nil || !vm.IsDone() {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	core, _ := vm.Core(1)
+	if core.cpu.GetRegister(register.R5) != 1000 {
+		t.Fatalf("expected worker to read timer 1000 times, got %d", core.cpu.GetRegister(register.R5))
+	}
+}
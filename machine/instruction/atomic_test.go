@@ -0,0 +1,94 @@
+package instruction
+
+import (
+	"testing"
+	"the-machine/machine/cpu"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+func runPackedInstructionWithMemory(packed []byte, cpu *cpu.Cpu, mem memory.MemoryAccess) error {
+	kind, decoded := Decode(uint16(packed[0]) | uint16(packed[1])<<8)
+	return Descriptors[kind].Executor.Execute(decoded, cpu, mem)
+}
+
+func Test_CompareAndSwap(t *testing.T) {
+	cpu := cpu.NewCpu()
+	mem := memory.NewMemory(16)
+	mem.SetUint16(4, 161)
+
+	cpu.SetRegister(register.Ac, 4)
+	cpu.SetRegister(register.R1, 13)
+	cpu.SetRegister(register.R2, 1312)
+	packed := CAS.Pack(register.R1.AsUint16(), register.R2.AsUint16())
+	if err := runPackedInstructionWithMemory(packed, cpu, mem); err != nil {
+		t.Fatalf("error executing compare and swap: %v", err)
+	}
+	if cpu.GetRegister(register.Ac) != 0 {
+		t.Fatalf("expected failed swap flag in Ac, got %d", cpu.GetRegister(register.Ac))
+	}
+	if cpu.GetRegister(register.R1) != 161 {
+		t.Fatalf("expected current value in R1, got %d", cpu.GetRegister(register.R1))
+	}
+	if value, _ := mem.GetUint16(4); value != 161 {
+		t.Fatalf("expected memory to stay unchanged on failed swap, got %d", value)
+	}
+
+	cpu.SetRegister(register.Ac, 4)
+	if err := runPackedInstructionWithMemory(packed, cpu, mem); err != nil {
+		t.Fatalf("error executing compare and swap: %v", err)
+	}
+	if cpu.GetRegister(register.Ac) != 1 {
+		t.Fatalf("expected successful swap flag in Ac, got %d", cpu.GetRegister(register.Ac))
+	}
+	if value, _ := mem.GetUint16(4); value != 1312 {
+		t.Fatalf("expected memory to be swapped, got %d", value)
+	}
+}
+
+func Test_FetchAdd(t *testing.T) {
+	cpu := cpu.NewCpu()
+	mem := memory.NewMemory(16)
+	mem.SetUint16(4, 161)
+
+	cpu.SetRegister(register.Ac, 4)
+	cpu.SetRegister(register.R1, 12)
+	packed := FADD.Pack(register.R1.AsUint16(), register.R2.AsUint16())
+	if err := runPackedInstructionWithMemory(packed, cpu, mem); err != nil {
+		t.Fatalf("error executing fetch and add: %v", err)
+	}
+	if cpu.GetRegister(register.R2) != 161 {
+		t.Fatalf("expected previous value in R2, got %d", cpu.GetRegister(register.R2))
+	}
+	if value, _ := mem.GetUint16(4); value != 173 {
+		t.Fatalf("expected memory to be incremented, got %d", value)
+	}
+}
+
+func Test_Exchange(t *testing.T) {
+	cpu := cpu.NewCpu()
+	mem := memory.NewMemory(16)
+	mem.SetUint16(4, 161)
+
+	cpu.SetRegister(register.Ac, 4)
+	cpu.SetRegister(register.R1, 1312)
+	if err := runPackedInstructionWithMemory(XCHG.Pack(register.R1.AsUint16()), cpu, mem); err != nil {
+		t.Fatalf("error executing exchange: %v", err)
+	}
+	if cpu.GetRegister(register.R1) != 161 {
+		t.Fatalf("expected previous value in R1, got %d", cpu.GetRegister(register.R1))
+	}
+	if value, _ := mem.GetUint16(4); value != 1312 {
+		t.Fatalf("expected memory to be exchanged, got %d", value)
+	}
+}
+
+func Test_Atomic_InvalidAddress(t *testing.T) {
+	cpu := cpu.NewCpu()
+	mem := memory.NewMemory(4)
+
+	cpu.SetRegister(register.Ac, 13)
+	if err := runPackedInstructionWithMemory(XCHG.Pack(register.R1.AsUint16()), cpu, mem); err == nil {
+		t.Fatalf("expected error exchanging memory outside bounds")
+	}
+}
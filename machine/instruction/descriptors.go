@@ -8,6 +8,7 @@ var Descriptors = map[Type]Instruction{
 	NOP: {
 		Description: "No-op",
 		Executor:    Passthrough{},
+		Cycles:      1,
 	},
 
 	// Data: registers
@@ -15,46 +16,57 @@ var Descriptors = map[Type]Instruction{
 	MOV_LIT_R1: {
 		Description: "Move literal to register R1",
 		Executor:    Lit2Reg{Target: register.R1},
+		Cycles:      1,
 	},
 	MOV_LIT_R2: {
 		Description: "Move literal to register R2",
 		Executor:    Lit2Reg{Target: register.R2},
+		Cycles:      1,
 	},
 	MOV_LIT_R3: {
 		Description: "Move literal to register R3",
 		Executor:    Lit2Reg{Target: register.R3},
+		Cycles:      1,
 	},
 	MOV_LIT_R4: {
 		Description: "Move literal to register R4",
 		Executor:    Lit2Reg{Target: register.R4},
+		Cycles:      1,
 	},
 	MOV_LIT_R5: {
 		Description: "Move literal to register R5",
 		Executor:    Lit2Reg{Target: register.R5},
+		Cycles:      1,
 	},
 	MOV_LIT_R6: {
 		Description: "Move literal to register R6",
 		Executor:    Lit2Reg{Target: register.R6},
+		Cycles:      1,
 	},
 	MOV_LIT_R7: {
 		Description: "Move literal to register R7",
 		Executor:    Lit2Reg{Target: register.R7},
+		Cycles:      1,
 	},
 	MOV_LIT_R8: {
 		Description: "Move literal to register R8",
 		Executor:    Lit2Reg{Target: register.R8},
+		Cycles:      1,
 	},
 	MOV_LIT_AC: {
 		Description: "Move literal to register Ac",
 		Executor:    Lit2Reg{Target: register.Ac},
+		Cycles:      1,
 	},
 	MOV_LIT_BNK: {
 		Description: "Move literal to register Bnk",
 		Executor:    Lit2Reg{Target: register.Bnk},
+		Cycles:      1,
 	},
 	MOV_REG_REG: {
 		Description: "Copy value from register to register",
 		Executor:    Reg2Reg{},
+		Cycles:      1,
 	},
 
 	// Data: memory
@@ -62,14 +74,17 @@ var Descriptors = map[Type]Instruction{
 	MOV_REG_MEM: {
 		Description: "Copy content of register to address in accumulator",
 		Executor:    Reg2Mem{},
+		Cycles:      3,
 	},
 	MOV_LIT_MEM: {
 		Description: "Move literal value to memory address in accumulator",
 		Executor:    Lit2Mem{},
+		Cycles:      3,
 	},
 	MOV_MEM_REG: {
 		Description: "Copy memory at address in register1 to register 2",
 		Executor:    Mem2Reg{},
+		Cycles:      3,
 	},
 
 	// Stack
@@ -77,14 +92,17 @@ var Descriptors = map[Type]Instruction{
 	PUSH_REG: {
 		Description: "Push value from register to stack",
 		Executor:    Reg2Stack{},
+		Cycles:      2,
 	},
 	PUSH_LIT: {
 		Description: "Push literal value to stack",
 		Executor:    Lit2Stack{},
+		Cycles:      2,
 	},
 	POP_REG: {
 		Description: "Pop value from stack to register",
 		Executor:    Stack2Reg{},
+		Cycles:      2,
 	},
 
 	// Stack math
@@ -92,18 +110,22 @@ var Descriptors = map[Type]Instruction{
 	ADD_STACK: {
 		Description: "Add top 2 stack values and push result",
 		Executor:    OperateStack{Operation: OpAdd},
+		Cycles:      5,
 	},
 	SUB_STACK: {
 		Description: "Subtract second stack value from stack head and push result",
 		Executor:    OperateStack{Operation: OpSub},
+		Cycles:      5,
 	},
 	MUL_STACK: {
 		Description: "Multiply top 2 stack values and push result",
 		Executor:    OperateStack{Operation: OpMul},
+		Cycles:      7,
 	},
 	DIV_STACK: {
 		Description: "Divide stack head by second stack value and push result",
 		Executor:    OperateStack{Operation: OpDiv},
+		Cycles:      10,
 	},
 
 	// Math
@@ -111,42 +133,52 @@ var Descriptors = map[Type]Instruction{
 	ADD_REG_REG: {
 		Description: "Add contents of two registers",
 		Executor:    OperateReg{Operation: OpAdd},
+		Cycles:      1,
 	},
 	ADD_REG_LIT: {
 		Description: "Add literal value to register (0-15)",
 		Executor:    OperateRegLit{Operation: OpAdd},
+		Cycles:      1,
 	},
 	SUB_REG_REG: {
 		Description: "Subtract contents of two registers",
 		Executor:    OperateReg{Operation: OpSub},
+		Cycles:      1,
 	},
 	SUB_REG_LIT: {
 		Description: "Sub literal value from register",
 		Executor:    OperateRegLit{Operation: OpSub},
+		Cycles:      1,
 	},
 	MUL_REG_REG: {
 		Description: "Multiply contents of two registers",
 		Executor:    OperateReg{Operation: OpMul},
+		Cycles:      3,
 	},
 	MUL_REG_LIT: {
 		Description: "Multiply register with literal value",
 		Executor:    OperateRegLit{Operation: OpMul},
+		Cycles:      3,
 	},
 	DIV_REG_REG: {
 		Description: "Divide contents of two registers",
 		Executor:    OperateReg{Operation: OpDiv},
+		Cycles:      6,
 	},
 	DIV_REG_LIT: {
 		Description: "Divide register with literal value",
 		Executor:    OperateRegLit{Operation: OpDiv},
+		Cycles:      6,
 	},
 	MOD_REG_REG: {
 		Description: "Remainder of contents of two registers division",
 		Executor:    OperateReg{Operation: OpMod},
+		Cycles:      6,
 	},
 	MOD_REG_LIT: {
 		Description: "Remainder of register with literal value division",
 		Executor:    OperateRegLit{Operation: OpMod},
+		Cycles:      6,
 	},
 
 	// Bitwise
@@ -154,34 +186,42 @@ var Descriptors = map[Type]Instruction{
 	SHL_REG_LIT: {
 		Description: "Shift left value in register by literal",
 		Executor:    OperateRegLit{Operation: OpShl},
+		Cycles:      1,
 	},
 	SHR_REG_LIT: {
 		Description: "Shift right value in register by literal",
 		Executor:    OperateRegLit{Operation: OpShr},
+		Cycles:      1,
 	},
 	AND_REG_LIT: {
 		Description: "ANDs value in register by literal",
 		Executor:    OperateRegLit{Operation: OpAnd},
+		Cycles:      1,
 	},
 	AND_REG_REG: {
 		Description: "ANDs value in register by register value",
 		Executor:    OperateReg{Operation: OpAnd},
+		Cycles:      1,
 	},
 	OR_REG_LIT: {
 		Description: "ORs value in register by literal",
 		Executor:    OperateRegLit{Operation: OpOr},
+		Cycles:      1,
 	},
 	OR_REG_REG: {
 		Description: "ORs value in register by register value",
 		Executor:    OperateReg{Operation: OpOr},
+		Cycles:      1,
 	},
 	XOR_REG_LIT: {
 		Description: "XORs value in register by literal",
 		Executor:    OperateRegLit{Operation: OpXor},
+		Cycles:      1,
 	},
 	XOR_REG_REG: {
 		Description: "XORs value in register by register value",
 		Executor:    OperateReg{Operation: OpXor},
+		Cycles:      1,
 	},
 
 	// Conditional jumps
@@ -189,26 +229,32 @@ var Descriptors = map[Type]Instruction{
 	JEQ: {
 		Description: "Jump to 2nd register address if Ac equal to 1st parameter register",
 		Executor:    Jump{Comparison: CompEq},
+		Cycles:      2,
 	},
 	JNE: {
 		Description: "Jump to 2nd register address if Ac not equal to 1st parameter register",
 		Executor:    Jump{Comparison: CompNe},
+		Cycles:      2,
 	},
 	JGT: {
 		Description: "Jump to 2nd register address if Ac greater than 1st parameter register",
 		Executor:    Jump{Comparison: CompGt},
+		Cycles:      2,
 	},
 	JGE: {
 		Description: "Jump to 2nd register address if Ac greater than or equal to 1st parameter register",
 		Executor:    Jump{Comparison: CompGe},
+		Cycles:      2,
 	},
 	JLT: {
 		Description: "Jump to 2nd register address if Ac less than 1st parameter register",
 		Executor:    Jump{Comparison: CompLt},
+		Cycles:      2,
 	},
 	JLE: {
 		Description: "Jump to 2nd register address if Ac less than or equal to 1st parameter register",
 		Executor:    Jump{Comparison: CompLe},
+		Cycles:      2,
 	},
 
 	// Subroutines
@@ -216,9 +262,81 @@ var Descriptors = map[Type]Instruction{
 	CALL: {
 		Description: "Call subroutine at address in register parameter",
 		Executor:    Call{},
+		Cycles:      8,
 	},
 	RET: {
 		Description: "Return from subroutine",
 		Executor:    Return{},
+		Cycles:      8,
+	},
+
+	// Cores
+
+	START_CORE: {
+		Description: "Start core with ID in 1st register at address in 2nd register",
+		Executor:    StartCore{},
+		Cycles:      4,
+	},
+	WAIT_CORE: {
+		Description: "Wait for core with ID in register parameter to halt",
+		Executor:    WaitCore{},
+		Cycles:      2,
+	},
+
+	// Atomics
+
+	CAS: {
+		Description: "Swap memory at address in Ac with 2nd register if it equals 1st register, Ac set to 1 on success",
+		Executor:    CompareAndSwap{},
+		Cycles:      5,
+	},
+	FADD: {
+		Description: "Add 1st register to memory at address in Ac, previous value to 2nd register",
+		Executor:    FetchAdd{},
+		Cycles:      5,
+	},
+	XCHG: {
+		Description: "Exchange register with memory at address in Ac",
+		Executor:    Exchange{},
+		Cycles:      4,
+	},
+
+	// Privilege
+
+	SYSCALL: {
+		Description: "Trap into supervisor with literal syscall number",
+		Executor:    Syscall{},
+		Cycles:      8,
+	},
+	SYSRET: {
+		Description: "Return from trap handler to interrupted code (supervisor only)",
+		Executor:    SysReturn{},
+		Cycles:      8,
+	},
+	ENTER_USER: {
+		Description: "Switch to user mode and jump to address in register (supervisor only)",
+		Executor:    EnterUser{},
+		Cycles:      4,
+	},
+
+	// Context switching
+
+	SAVE_CTX: {
+		Description: "Save register file and stack to memory block at address in register (supervisor only)",
+		Executor:    SaveContext{},
+		Cycles:      16,
+	},
+	LOAD_CTX: {
+		Description: "Load register file and stack from memory block at address in register (supervisor only)",
+		Executor:    LoadContext{},
+		Cycles:      16,
+	},
+
+	// Address translation
+
+	TLB_FLUSH: {
+		Description: "Drop cached address translations, after page table edits (supervisor only)",
+		Executor:    FlushTlb{},
+		Cycles:      4,
 	},
 }
@@ -30,8 +30,7 @@ func (x Lit2Reg) String() string {
 }
 
 func (x Lit2Reg) Execute(value uint16, cpu *cpu.Cpu, mem memory.MemoryAccess) error {
-	cpu.SetRegister(x.Target, value)
-	return nil
+	return cpu.WriteRegister(x.Target, value)
 }
 
 type Reg2Reg struct{ unpacker }
@@ -52,8 +51,7 @@ func (x Reg2Reg) Execute(raw uint16, cpu *cpu.Cpu, mem memory.MemoryAccess) erro
 		return internal.Error(fmt.Sprintf("invalid destination register (%#02x)", params[1]), err, internal.ErrorReg2Reg)
 	}
 
-	cpu.SetRegister(destination, value)
-	return nil
+	return cpu.WriteRegister(destination, value)
 }
 
 type Reg2Stack struct{}
@@ -87,14 +85,16 @@ func (x Stack2Reg) Execute(raw uint16, cpu *cpu.Cpu, mem memory.MemoryAccess) er
 	if err != nil {
 		return internal.Error(fmt.Sprintf("invalid source register (%#02x)", raw), err, internal.ErrorStack2Reg)
 	}
+	if err := cpu.CheckRegisterWrite(destination); err != nil {
+		return err
+	}
 
 	value, err := cpu.Pop()
 	if err != nil {
 		return internal.Error("stack underflow", err, internal.ErrorStack2Reg)
 	}
 
-	cpu.SetRegister(destination, value)
-	return nil
+	return cpu.WriteRegister(destination, value)
 }
 
 type Ac2Reg struct{}
@@ -109,8 +109,7 @@ func (x Ac2Reg) Execute(params uint16, cpu *cpu.Cpu, mem memory.MemoryAccess) er
 		return internal.Error(fmt.Sprintf("invalid destination register (%#02x)", params), err, internal.ErrorAc2Reg)
 	}
 
-	cpu.SetRegister(destination, value)
-	return nil
+	return cpu.WriteRegister(destination, value)
 }
 
 type Reg2Mem struct{}
@@ -160,8 +159,7 @@ func (x Mem2Reg) Execute(raw uint16, cpu *cpu.Cpu, mem memory.MemoryAccess) erro
 		return internal.Error(fmt.Sprintf("error accessing memory at %d (%#02x)", address, address), err, internal.ErrorMem2Reg)
 	}
 
-	cpu.SetRegister(destination, value)
-	return nil
+	return cpu.WriteRegister(destination, value)
 }
 
 type OperateReg struct {
@@ -412,3 +410,307 @@ func (x Return) Execute(_ uint16, cpu *cpu.Cpu, _ memory.MemoryAccess) error {
 	}
 	return nil
 }
+
+type StartCore struct{ unpacker }
+
+func (x StartCore) String() string { return "" }
+
+func (x StartCore) Execute(raw uint16, cpu *cpu.Cpu, _ memory.MemoryAccess) error {
+	params := x.unpack(raw)
+
+	cr, err := register.FromByte(params[0])
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid core register (%#02x)", params[0]), err, internal.ErrorCore)
+	}
+	id := cpu.GetRegister(cr)
+
+	ar, err := register.FromByte(params[1])
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid address register (%#02x)", params[1]), err, internal.ErrorCore)
+	}
+	address := cpu.GetRegister(ar)
+
+	controller, err := cpu.GetController()
+	if err != nil {
+		return internal.Error(fmt.Sprintf("unable to start core %d", id), err, internal.ErrorCore)
+	}
+	if err := controller.StartCore(id, address); err != nil {
+		return internal.Error(fmt.Sprintf("unable to start core %d at %d", id, address), err, internal.ErrorCore)
+	}
+	return nil
+}
+
+type WaitCore struct{}
+
+func (x WaitCore) String() string { return "" }
+
+func (x WaitCore) Execute(raw uint16, cpu *cpu.Cpu, _ memory.MemoryAccess) error {
+	reg, err := register.FromByte(byte(raw))
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid core register (%#02x)", raw), err, internal.ErrorCore)
+	}
+	id := cpu.GetRegister(reg)
+	if id == cpu.GetRegister(register.Cid) {
+		return internal.Error(fmt.Sprintf("core %d can't wait on itself", id), nil, internal.ErrorCore)
+	}
+
+	controller, err := cpu.GetController()
+	if err != nil {
+		return internal.Error(fmt.Sprintf("unable to wait on core %d", id), err, internal.ErrorCore)
+	}
+	running, err := controller.IsCoreRunning(id)
+	if err != nil {
+		return internal.Error(fmt.Sprintf("unable to wait on core %d", id), err, internal.ErrorCore)
+	}
+	if running {
+		// Re-execute this instruction on next tick
+		cpu.SetRegister(register.Ip, cpu.GetRegister(register.Ip)-2)
+	}
+	return nil
+}
+
+// Runs memory operation atomically, if the memory supports it
+func atomically(mem memory.MemoryAccess, fn func(memory.MemoryAccess) error) error {
+	if atomic, ok := mem.(memory.AtomicAccess); ok {
+		return atomic.Atomically(fn)
+	}
+	return fn(mem)
+}
+
+type CompareAndSwap struct{ unpacker }
+
+func (x CompareAndSwap) String() string { return "" }
+
+func (x CompareAndSwap) Execute(raw uint16, cpu *cpu.Cpu, mem memory.MemoryAccess) error {
+	params := x.unpack(raw)
+
+	er, err := register.FromByte(params[0])
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid expected value register (%#02x)", params[0]), err, internal.ErrorAtomic)
+	}
+	nr, err := register.FromByte(params[1])
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid new value register (%#02x)", params[1]), err, internal.ErrorAtomic)
+	}
+	if err := cpu.CheckRegisterWrite(er); err != nil {
+		return err
+	}
+	expected := cpu.GetRegister(er)
+	value := cpu.GetRegister(nr)
+	address := memory.Address(cpu.GetRegister(register.Ac))
+
+	var current uint16
+	err = atomically(mem, func(mem memory.MemoryAccess) error {
+		var err error
+		if current, err = mem.GetUint16(address); err != nil {
+			return err
+		}
+		if current != expected {
+			return nil
+		}
+		return mem.SetUint16(address, value)
+	})
+	if err != nil {
+		return internal.Error(fmt.Sprintf("error swapping memory at %d (%#02x)", address, address), err, internal.ErrorAtomic)
+	}
+
+	cpu.SetRegister(er, current)
+	if current == expected {
+		cpu.SetRegister(register.Ac, 1)
+	} else {
+		cpu.SetRegister(register.Ac, 0)
+	}
+	return nil
+}
+
+type FetchAdd struct{ unpacker }
+
+func (x FetchAdd) String() string { return "" }
+
+func (x FetchAdd) Execute(raw uint16, cpu *cpu.Cpu, mem memory.MemoryAccess) error {
+	params := x.unpack(raw)
+
+	vr, err := register.FromByte(params[0])
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid value register (%#02x)", params[0]), err, internal.ErrorAtomic)
+	}
+	dr, err := register.FromByte(params[1])
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid destination register (%#02x)", params[1]), err, internal.ErrorAtomic)
+	}
+	if err := cpu.CheckRegisterWrite(dr); err != nil {
+		return err
+	}
+	value := cpu.GetRegister(vr)
+	address := memory.Address(cpu.GetRegister(register.Ac))
+
+	var previous uint16
+	err = atomically(mem, func(mem memory.MemoryAccess) error {
+		var err error
+		if previous, err = mem.GetUint16(address); err != nil {
+			return err
+		}
+		return mem.SetUint16(address, previous+value)
+	})
+	if err != nil {
+		return internal.Error(fmt.Sprintf("error adding to memory at %d (%#02x)", address, address), err, internal.ErrorAtomic)
+	}
+
+	cpu.SetRegister(dr, previous)
+	return nil
+}
+
+type Exchange struct{}
+
+func (x Exchange) String() string { return "" }
+
+func (x Exchange) Execute(raw uint16, cpu *cpu.Cpu, mem memory.MemoryAccess) error {
+	reg, err := register.FromByte(byte(raw))
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid register (%#02x)", raw), err, internal.ErrorAtomic)
+	}
+	if err := cpu.CheckRegisterWrite(reg); err != nil {
+		return err
+	}
+	value := cpu.GetRegister(reg)
+	address := memory.Address(cpu.GetRegister(register.Ac))
+
+	var previous uint16
+	err = atomically(mem, func(mem memory.MemoryAccess) error {
+		var err error
+		if previous, err = mem.GetUint16(address); err != nil {
+			return err
+		}
+		return mem.SetUint16(address, value)
+	})
+	if err != nil {
+		return internal.Error(fmt.Sprintf("error exchanging memory at %d (%#02x)", address, address), err, internal.ErrorAtomic)
+	}
+
+	cpu.SetRegister(reg, previous)
+	return nil
+}
+
+type Syscall struct{}
+
+func (x Syscall) String() string { return "" }
+
+func (x Syscall) Execute(raw uint16, _ *cpu.Cpu, _ memory.MemoryAccess) error {
+	return cpu.RaiseTrap(cpu.TrapSyscall, raw, "syscall")
+}
+
+func requireSupervisor(c *cpu.Cpu) error {
+	if c.GetMode() != cpu.Supervisor {
+		return cpu.RaiseTrap(cpu.TrapPrivilege, 0, "instruction is supervisor-only")
+	}
+	return nil
+}
+
+type SysReturn struct{}
+
+func (x SysReturn) String() string { return "" }
+
+func (x SysReturn) Execute(_ uint16, cpu *cpu.Cpu, _ memory.MemoryAccess) error {
+	if err := requireSupervisor(cpu); err != nil {
+		return err
+	}
+	if err := cpu.ReturnFromTrap(); err != nil {
+		return internal.Error("unable to return from trap", err, internal.ErrorSyscall)
+	}
+	return nil
+}
+
+type EnterUser struct{}
+
+func (x EnterUser) String() string { return "" }
+
+func (x EnterUser) Execute(raw uint16, c *cpu.Cpu, _ memory.MemoryAccess) error {
+	if err := requireSupervisor(c); err != nil {
+		return err
+	}
+	reg, err := register.FromByte(byte(raw))
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid address register (%#02x)", raw), err, internal.ErrorSyscall)
+	}
+	c.SetRegister(register.Ip, c.GetRegister(reg))
+	c.SetMode(cpu.User)
+	return nil
+}
+
+type SaveContext struct{}
+
+func (x SaveContext) String() string { return "" }
+
+func (x SaveContext) Execute(raw uint16, c *cpu.Cpu, mem memory.MemoryAccess) error {
+	if err := requireSupervisor(c); err != nil {
+		return err
+	}
+	reg, err := register.FromByte(byte(raw))
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid address register (%#02x)", raw), err, internal.ErrorContext)
+	}
+	address := memory.Address(c.GetRegister(reg))
+
+	values := c.SaveContext()
+	return atomically(mem, func(mem memory.MemoryAccess) error {
+		for idx, value := range values {
+			at := address + memory.Address(idx*2)
+			if err := mem.SetUint16(at, value); err != nil {
+				return internal.Error(fmt.Sprintf("unable to save context at %d (%#02x)", at, at), err, internal.ErrorContext)
+			}
+		}
+		return nil
+	})
+}
+
+type LoadContext struct{}
+
+func (x LoadContext) String() string { return "" }
+
+func (x LoadContext) Execute(raw uint16, c *cpu.Cpu, mem memory.MemoryAccess) error {
+	if err := requireSupervisor(c); err != nil {
+		return err
+	}
+	reg, err := register.FromByte(byte(raw))
+	if err != nil {
+		return internal.Error(fmt.Sprintf("invalid address register (%#02x)", raw), err, internal.ErrorContext)
+	}
+	address := memory.Address(c.GetRegister(reg))
+
+	var values []uint16
+	err = atomically(mem, func(mem memory.MemoryAccess) error {
+		load := func(count int) error {
+			for idx := len(values); idx < count; idx++ {
+				at := address + memory.Address(idx*2)
+				value, err := mem.GetUint16(at)
+				if err != nil {
+					return internal.Error(fmt.Sprintf("unable to load context from %d (%#02x)", at, at), err, internal.ErrorContext)
+				}
+				values = append(values, value)
+			}
+			return nil
+		}
+		// Header tells how much of the stack follows
+		if err := load(cpu.ContextHeader); err != nil {
+			return err
+		}
+		return load(cpu.ContextHeader + cpu.ContextStackWords(values))
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.LoadContext(values)
+}
+
+type FlushTlb struct{}
+
+func (x FlushTlb) String() string { return "" }
+
+func (x FlushTlb) Execute(_ uint16, c *cpu.Cpu, _ memory.MemoryAccess) error {
+	if err := requireSupervisor(c); err != nil {
+		return err
+	}
+	c.InvalidateTranslations()
+	return nil
+}
@@ -11,6 +11,7 @@ type Instruction struct {
 	Description string
 	Raw         uint16
 	Executor    Executor
+	Cycles      uint64
 }
 
 func (x Instruction) Execute(cpu *cpu.Cpu, memory memory.MemoryAccess) error {
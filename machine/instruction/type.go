@@ -71,6 +71,22 @@ const (
 
 	HALT Type = iota
 
+	START_CORE Type = iota
+	WAIT_CORE  Type = iota
+
+	CAS  Type = iota
+	FADD Type = iota
+	XCHG Type = iota
+
+	SYSCALL    Type = iota
+	SYSRET     Type = iota
+	ENTER_USER Type = iota
+
+	SAVE_CTX Type = iota
+	LOAD_CTX Type = iota
+
+	TLB_FLUSH Type = iota
+
 	_sizeofType = iota
 )
 
@@ -17,8 +17,14 @@ const (
 	ErrorJmp       MachineErrorSource = "Jmp"
 	ErrorCall      MachineErrorSource = "Call"
 	ErrorRet       MachineErrorSource = "Ret"
+	ErrorCore      MachineErrorSource = "Core"
+	ErrorAtomic    MachineErrorSource = "Atomic"
+	ErrorSyscall   MachineErrorSource = "Syscall"
+	ErrorContext   MachineErrorSource = "Context"
 
 	ErrorMemory      MachineErrorSource = "Memory"
+	ErrorProtection  MachineErrorSource = "Protection"
+	ErrorDevice      MachineErrorSource = "Device"
 	ErrorCpu         MachineErrorSource = "Cpu"
 	ErrorInstruction MachineErrorSource = "Instruction"
 	ErrorInterface   MachineErrorSource = "Interface"
@@ -2,6 +2,7 @@ package machine
 
 import (
 	"fmt"
+	"sync"
 	"the-machine/machine/cpu"
 	"the-machine/machine/debug"
 	"the-machine/machine/device"
@@ -31,23 +32,55 @@ const (
 )
 
 type Machine struct {
-	cpu    *cpu.Cpu
-	memory MemoryMap
-	status Status
-	cycle  Cycle
+	cpu        *cpu.Cpu
+	memory     MemoryMap
+	memoryLock *sync.Mutex
+	clock      *clock
+	protection ProtectionMap
+	mmu        *MMU
+	cache      *Cache
+	preemption *preemption
+	devices    *deviceSet
+	traps      map[cpu.Trap]memory.Address
+	status     Status
+	cycle      Cycle
 }
 
 func NewMachine(memsize int) Machine {
 	return Machine{
-		cpu:    cpu.NewCpu(),
-		memory: NewMemoryMap(memsize, memsize),
-		status: Ready,
-		cycle:  Idle,
+		cpu:        cpu.NewCpu(),
+		memory:     NewMemoryMap(memsize, memsize),
+		memoryLock: &sync.Mutex{},
+		clock:      newClock(),
+		protection: ProtectionMap{},
+		preemption: &preemption{},
+		devices:    &deviceSet{},
+		traps:      map[cpu.Trap]memory.Address{},
+		status:     Ready,
+		cycle:      Idle,
+	}
+}
+
+func newCore(id uint16, controller cpu.Controller, mem MemoryMap, lock *sync.Mutex) *Machine {
+	return &Machine{
+		cpu:        cpu.NewCore(id, controller),
+		memory:     mem,
+		memoryLock: lock,
+		clock:      newClock(),
+		protection: ProtectionMap{},
+		preemption: &preemption{},
+		devices:    &deviceSet{},
+		traps:      map[cpu.Trap]memory.Address{},
+		status:     Ready,
+		cycle:      Idle,
 	}
 }
 
 func (vm *Machine) Reset() {
 	vm.cpu.Reset()
+	vm.clock.reset()
+	vm.preemption.reset()
+	vm.resetDevices()
 	vm.status = Ready
 	vm.cycle = Idle
 }
@@ -70,7 +103,7 @@ func (vm *Machine) GetIO() (*device.IOMap, error) {
 	if err != nil {
 		return io, internal.Error("unable to access IO", err, internal.ErrorRuntime)
 	}
-	io, ok := raw.(*device.IOMap)
+	io, ok := memory.Unwrap(raw).(*device.IOMap)
 	if !ok {
 		return io, internal.Error("unable to access IO", nil, internal.ErrorRuntime)
 	}
@@ -79,18 +112,66 @@ func (vm *Machine) GetIO() (*device.IOMap, error) {
 
 func NewWithMemory(mem memory.MemoryAccess, ramSize int) Machine {
 	return Machine{
-		cpu:    cpu.NewCpu(),
-		memory: NewMemoryMap(ramSize, ramSize),
-		status: Ready,
-		cycle:  Idle,
+		cpu:        cpu.NewCpu(),
+		memory:     NewMemoryMap(ramSize, ramSize),
+		memoryLock: &sync.Mutex{},
+		clock:      newClock(),
+		protection: ProtectionMap{},
+		preemption: &preemption{},
+		devices:    &deviceSet{},
+		traps:      map[cpu.Trap]memory.Address{},
+		status:     Ready,
+		cycle:      Idle,
+	}
+}
+
+// Observe notifies observer of every access to memory bank
+func (vm *Machine) Observe(kind memory.MemoryType, observer memory.Observer) error {
+	mem, err := vm.getMemory(kind)
+	if err != nil {
+		return internal.Error(fmt.Sprintf("unable to observe %s", kind), err, internal.ErrorMemory)
+	}
+	observed, ok := mem.(*memory.Observed)
+	if !ok {
+		observed = memory.NewObserved(kind, mem)
+		vm.memory[kind] = observed
+	}
+	observed.AddObserver(observer)
+	return nil
+}
+
+// NewWithMemoryMap creates machine with custom set of memory banks and devices
+func NewWithMemoryMap(mem MemoryMap) Machine {
+	return Machine{
+		cpu:        cpu.NewCpu(),
+		memory:     mem,
+		memoryLock: &sync.Mutex{},
+		clock:      newClock(),
+		protection: ProtectionMap{},
+		preemption: &preemption{},
+		devices:    &deviceSet{},
+		traps:      map[cpu.Trap]memory.Address{},
+		status:     Ready,
+		cycle:      Idle,
 	}
 }
 
+func (vm *Machine) SetStackSize(size int) error {
+	return vm.cpu.SetStackSize(size)
+}
+
 func (vm *Machine) LoadProgram(at memory.Address, program []byte) error {
 	rom, err := vm.getMemory(memory.ROM)
 	if err != nil {
 		return internal.Error("unable to access ROM", nil, internal.ErrorRuntime)
 	}
+	if flasher, ok := memory.Unwrap(rom).(memory.Flasher); ok {
+		if err := flasher.Flash(at, program); err != nil {
+			return internal.Error(fmt.Sprintf("error loading program at %d", at), err, internal.ErrorLoading)
+		}
+		vm.status = Loaded
+		return nil
+	}
 	for idx, b := range program {
 		if err := rom.SetByte(at+memory.Address(idx), b); err != nil {
 			return internal.Error(fmt.Sprintf("error loading program at %d+%d (%#02x)", at, idx, b), err, internal.ErrorLoading)
@@ -109,9 +190,21 @@ func (vm *Machine) fetch() (uint16, error) {
 	if err != nil {
 		return 0, internal.Error("unable to access ROM", nil, internal.ErrorRuntime)
 	}
-	instr, err := rom.GetUint16(ipAddr)
+	if observed, ok := rom.(*memory.Observed); ok {
+		rom = observed.Fetching()
+	}
+	code, err := vm.translate(memory.ROM, protectedMemory{
+		mem:        vm.cached(memory.ROM, rom),
+		kind:       memory.ROM,
+		protection: vm.protection,
+		cpu:        vm.cpu,
+		fetch:      true,
+	})
+	if err != nil {
+		return 0, internal.Error("unable to access ROM", err, internal.ErrorRuntime)
+	}
+	instr, err := lockedMemory{mem: code, lock: vm.memoryLock}.GetUint16(ipAddr)
 	if err != nil {
-		vm.status = Error
 		return instr, internal.Error("unable to get next instruction", err, internal.ErrorRuntime)
 	}
 
@@ -147,32 +240,81 @@ func (vm *Machine) execute(instr instruction.Instruction) error {
 	if err != nil {
 		return internal.Error("unable to access memory", err, internal.ErrorRuntime)
 	}
-	if err := instr.Execute(vm.cpu, mem); err != nil {
-		vm.status = Error
+	bank := memory.MemoryType(vm.cpu.GetRegister(register.Bnk))
+	guarded, err := vm.translate(bank, protectedMemory{
+		mem:        vm.cached(bank, mem),
+		kind:       bank,
+		protection: vm.protection,
+		cpu:        vm.cpu,
+	})
+	if err != nil {
+		return internal.Error("unable to access memory", err, internal.ErrorRuntime)
+	}
+	if err := instr.Execute(vm.cpu, lockedMemory{mem: guarded, lock: vm.memoryLock}); err != nil {
 		return internal.Error(fmt.Sprintf("error executing %#02x", instr), err, internal.ErrorRuntime)
 	}
 	return nil
 }
 
+// trapOrFail enters trap handler for handled trap requests,
+// otherwise puts machine into error state
+func (vm *Machine) trapOrFail(err error, ip uint16) error {
+	handled, terr := vm.handleTrap(err, ip)
+	if handled {
+		return nil
+	}
+	vm.status = Error
+	if terr != nil {
+		return terr
+	}
+	return err
+}
+
 func (vm *Machine) Tick() error {
 	if vm.IsDone() {
 		return nil
 	}
 	vm.status = Running
 	vm.cycle = Idle
+	ip := vm.cpu.GetRegister(register.Ip)
 
+	// Fetch faults are accounted for as NOP, so that time keeps going
+	decoded := instruction.Descriptors[instruction.NOP]
 	next, err := vm.fetch()
 	if err != nil {
-		return internal.Error("unable to fetch next tick", err, internal.ErrorRuntime)
+		if err := vm.trapOrFail(err, ip); err != nil {
+			return internal.Error("unable to fetch next tick", err, internal.ErrorRuntime)
+		}
+	} else {
+		if decoded, err = vm.decode(next); err != nil {
+			return internal.Error(fmt.Sprintf("unable to decode instruction: %#02x", next), err, internal.ErrorRuntime)
+		}
+		if err := vm.execute(decoded); err != nil {
+			if err := vm.trapOrFail(err, ip); err != nil {
+				return internal.Error("unable to execute tick", err, internal.ErrorRuntime)
+			}
+		}
 	}
 
-	decoded, err := vm.decode(next)
-	if err != nil {
-		return internal.Error(fmt.Sprintf("unable to decode instruction: %#02x", next), err, internal.ErrorRuntime)
+	vm.cpu.AddCycles(decoded.Cycles)
+	if vm.cache != nil {
+		vm.cpu.AddCycles(vm.cache.cycles())
 	}
+	vm.clock.throttle(vm.cpu.GetCycles())
 
-	if err := vm.execute(decoded); err != nil {
-		return internal.Error("unable to execute tick", err, internal.ErrorRuntime)
+	if err := vm.tickDevices(decoded.Cycles); err != nil {
+		vm.status = Error
+		return internal.Error("unable to tick devices", err, internal.ErrorRuntime)
+	}
+
+	if err := vm.preempt(decoded.Cycles); err != nil {
+		vm.status = Error
+		return internal.Error("unable to preempt", err, internal.ErrorRuntime)
+	}
+
+	if err := vm.interrupt(); err != nil {
+		vm.status = Error
+		return internal.Error("unable to interrupt", err, internal.ErrorRuntime)
 	}
 
 	vm.cycle = Idle
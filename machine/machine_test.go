@@ -1,8 +1,10 @@
 package machine
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"the-machine/machine/device"
 	"the-machine/machine/instruction"
 	"the-machine/machine/internal"
 	"the-machine/machine/memory"
@@ -871,3 +873,72 @@ func Test_Machine_Jle(t *testing.T) {
 		t.Fatalf("error in Accumulator: %d", vm.cpu.GetRegister(register.Ac))
 	}
 }
+
+func Test_Machine_RomWriteProtect(t *testing.T) {
+	vm := NewMachine(255)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_BNK.Pack(uint16(memory.ROM)),
+		instruction.MOV_LIT_AC.Pack(0),
+		instruction.MOV_LIT_MEM.Pack(161),
+	))
+
+	if _, err := run(vm); !errors.Is(err, memory.ErrWriteProtect) {
+		t.Fatalf("expected write-protect error overwriting program, got: %v", err)
+	}
+	rom, _ := vm.GetBank(memory.ROM)
+	if x, _ := rom.GetUint16(0); x == 161 {
+		t.Fatalf("expected program to stay intact")
+	}
+}
+
+func Test_Machine_Observe(t *testing.T) {
+	vm := NewMachine(255)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_AC.Pack(100),
+		instruction.MOV_LIT_MEM.Pack(161),
+		instruction.MOV_LIT_MEM.Pack(13),
+	))
+	var writes []memory.Access
+	vm.Observe(memory.RAM, memory.ObserverFunc(func(access memory.Access) {
+		if access.Operation.IsWrite() {
+			writes = append(writes, access)
+		}
+	}))
+	fetches := 0
+	vm.Observe(memory.ROM, memory.ObserverFunc(func(memory.Access) {
+		fetches++
+	}))
+
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	if len(writes) != 2 || writes[1].At != 100 || writes[1].Old != 161 || writes[1].New != 13 {
+		t.Fatalf("unexpected writes observed: %v", writes)
+	}
+	if fetches != 4 {
+		t.Fatalf("expected each instruction fetch to be observed, got %d", fetches)
+	}
+}
+
+func Test_Machine_ReadsScreen(t *testing.T) {
+	vm := NewMachine(255)
+	vga := device.NewHeadlessVideo()
+	vm.AttachMemory(memory.DeviceVGA, vga)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_BNK.Pack(uint16(memory.DeviceVGA)),
+		instruction.MOV_LIT_AC.Pack(258),
+		instruction.MOV_LIT_MEM.Pack('A'),
+		instruction.MOV_LIT_R1.Pack(258),
+		instruction.MOV_MEM_REG.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+	))
+
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	if vm.cpu.GetRegister(register.R2) != 'A' {
+		t.Fatalf("expected to read back screen contents, got %d", vm.cpu.GetRegister(register.R2))
+	}
+	if lines := vga.(device.Video).Lines(); len(lines) != 2 || lines[1] != "   A" {
+		t.Fatalf("unexpected screen: %q", lines)
+	}
+}
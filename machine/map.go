@@ -1,7 +1,9 @@
 package machine
 
 import (
+	"fmt"
 	"the-machine/machine/device"
+	"the-machine/machine/internal"
 	"the-machine/machine/memory"
 )
 
@@ -9,9 +11,136 @@ type MemoryMap map[memory.MemoryType]memory.MemoryAccess
 
 func NewMemoryMap(ramSize int, romSize int) MemoryMap {
 	return map[memory.MemoryType]memory.MemoryAccess{
-		memory.RAM:       memory.NewMemory(ramSize),
-		memory.ROM:       memory.NewMemory(romSize),
+		memory.RAM:       memory.NewPagedMemory(ramSize),
+		memory.ROM:       memory.NewRom(romSize),
 		memory.DeviceVGA: device.NewVideo(),
 		memory.DeviceIO:  device.NewIoMap(),
 	}
 }
+
+// IO descriptors and control registers window size on the bus
+const busIoSize = int(device.IoControlEnd)
+
+// addressSpace is the size of flat address space, 16-bit addresses
+const addressSpace = 0x10000
+
+type sized interface {
+	Size() int
+}
+
+// NewBus maps banks into flat address space: RAM from 0, then ROM,
+// then IO descriptors, with VGA taking up the rest of the addresses.
+// Banks that don't fit into the address space are an error,
+// such as full size RAM and ROM of NewMachine(0xffff).
+// Banks are looked up on each access, so bus traffic goes through
+// banks wrapped or replaced after the bus was built, such as observed ones.
+func (x MemoryMap) NewBus() (*memory.Bus, error) {
+	bus := memory.NewBus()
+	var at int
+	attach := func(kind memory.MemoryType, size int) error {
+		if size <= 0 || size > addressSpace-at {
+			return internal.Error(fmt.Sprintf("%s needs %d bytes at %d, with %d left in address space", kind, size, at, addressSpace-at), nil, internal.ErrorMemory)
+		}
+		if err := bus.Attach(kind, memory.Address(at), size, mappedBank{banks: x, kind: kind}); err != nil {
+			return internal.Error(fmt.Sprintf("unable to map %s", kind), err, internal.ErrorMemory)
+		}
+		at += size
+		return nil
+	}
+	for _, kind := range []memory.MemoryType{memory.RAM, memory.ROM} {
+		mem, ok := x[kind]
+		if !ok {
+			return bus, internal.Error(fmt.Sprintf("no %s to map", kind), nil, internal.ErrorMemory)
+		}
+		s, ok := memory.Unwrap(mem).(sized)
+		if !ok {
+			return bus, internal.Error(fmt.Sprintf("unknown %s size", kind), nil, internal.ErrorMemory)
+		}
+		if err := attach(kind, s.Size()); err != nil {
+			return bus, err
+		}
+	}
+	if _, ok := x[memory.DeviceIO]; ok {
+		if err := attach(memory.DeviceIO, busIoSize); err != nil {
+			return bus, err
+		}
+	}
+	if _, ok := x[memory.DeviceVGA]; ok {
+		if err := attach(memory.DeviceVGA, addressSpace-at); err != nil {
+			return bus, err
+		}
+	}
+	return bus, nil
+}
+
+// mappedBank is memory map bank, looked up on each access
+type mappedBank struct {
+	banks MemoryMap
+	kind  memory.MemoryType
+}
+
+func (x mappedBank) bank() (memory.MemoryAccess, error) {
+	if mem, ok := x.banks[x.kind]; ok {
+		return mem, nil
+	}
+	return nil, internal.Error(fmt.Sprintf("%s is no longer mapped", x.kind), nil, internal.ErrorMemory)
+}
+
+func (x mappedBank) GetByte(at memory.Address) (byte, error) {
+	mem, err := x.bank()
+	if err != nil {
+		return 0, err
+	}
+	return mem.GetByte(at)
+}
+
+func (x mappedBank) GetUint16(at memory.Address) (uint16, error) {
+	mem, err := x.bank()
+	if err != nil {
+		return 0, err
+	}
+	return mem.GetUint16(at)
+}
+
+func (x mappedBank) SetByte(at memory.Address, value byte) error {
+	mem, err := x.bank()
+	if err != nil {
+		return err
+	}
+	return mem.SetByte(at, value)
+}
+
+func (x mappedBank) SetUint16(at memory.Address, value uint16) error {
+	mem, err := x.bank()
+	if err != nil {
+		return err
+	}
+	return mem.SetUint16(at, value)
+}
+
+// AttachBus makes flat address space available as memory.Flat bank,
+// alongside the individual banks. The bank is supervisor-only, with
+// protection of the banks mapped to it applied.
+func (vm *Machine) AttachBus(bus *memory.Bus) {
+	vm.memory[memory.Flat] = bus
+}
+
+// AttachDefaultBus maps machine banks to flat address space with default layout
+func (vm *Machine) AttachDefaultBus() (*memory.Bus, error) {
+	bus, err := vm.memory.NewBus()
+	if err != nil {
+		return bus, internal.Error("unable to create bus", err, internal.ErrorMemory)
+	}
+	vm.AttachBus(bus)
+	return bus, nil
+}
+
+// AttachMemory adds or replaces memory bank, e.g. memory.Persisted
+func (vm *Machine) AttachMemory(kind memory.MemoryType, mem memory.MemoryAccess) {
+	vm.memory[kind] = mem
+}
+
+// GetBank gives access to memory bank regardless of current Bnk
+func (vm *Machine) GetBank(kind memory.MemoryType) (memory.MemoryAccess, error) {
+	return vm.getMemory(kind)
+}
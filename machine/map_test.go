@@ -0,0 +1,139 @@
+package machine
+
+import (
+	"path/filepath"
+	"testing"
+	"the-machine/machine/cpu"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+func Test_Bus_DefaultLayout(t *testing.T) {
+	vm := NewMachine(255)
+	program := packProgram(
+		instruction.MOV_LIT_BNK.Pack(uint16(memory.Flat)),
+		instruction.MOV_LIT_AC.Pack(100),
+		instruction.MOV_LIT_MEM.Pack(161),
+		instruction.MOV_LIT_R1.Pack(255),
+		instruction.MOV_MEM_REG.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+	)
+	vm.LoadProgram(0, program)
+
+	bus, err := vm.AttachDefaultBus()
+	if err != nil {
+		t.Fatalf("unable to attach bus: %v", err)
+	}
+	if r, offset, err := bus.Resolve(255 + 255); err != nil || r.Kind != memory.DeviceIO || offset != 0 {
+		t.Fatalf("expected IO right after ROM, got %s+%d and error %v", r.Kind, offset, err)
+	}
+
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+
+	ram, _ := vm.GetBank(memory.RAM)
+	if x, _ := ram.GetUint16(100); x != 161 {
+		t.Fatalf("expected write through bus to land in RAM, got %d", x)
+	}
+	rom, _ := vm.GetBank(memory.ROM)
+	if x, _ := rom.GetUint16(0); vm.cpu.GetRegister(register.R2) != x {
+		t.Fatalf("expected to read ROM through bus, got %#04x", vm.cpu.GetRegister(register.R2))
+	}
+}
+
+func Test_Machine_PersistedMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter.bin")
+	program := packProgram(
+		instruction.MOV_LIT_BNK.Pack(uint16(memory.Persisted)),
+		instruction.MOV_LIT_R1.Pack(0),
+		instruction.MOV_MEM_REG.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+		instruction.ADD_REG_LIT.Pack(register.R2.AsUint16(), 1),
+		instruction.MOV_REG_REG.Pack(register.Ac.AsUint16(), register.R2.AsUint16()),
+		instruction.MOV_LIT_AC.Pack(0),
+		instruction.MOV_REG_MEM.Pack(register.R2.AsUint16()),
+	)
+
+	for i := 1; i <= 2; i++ {
+		mem, err := memory.OpenFile(path, 16)
+		if err != nil {
+			t.Fatalf("unable to open memory file: %v", err)
+		}
+		vm := NewMachine(255)
+		vm.AttachMemory(memory.Persisted, mem)
+		vm.LoadProgram(0, program)
+		if steps, err := run(vm); err != nil {
+			t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+		}
+		mem.Close()
+
+		if vm.cpu.GetRegister(register.R2) != uint16(i) {
+			t.Fatalf("expected counter to persist across runs, run %d got %d", i, vm.cpu.GetRegister(register.R2))
+		}
+	}
+}
+
+func Test_Bus_FullSizeBanks(t *testing.T) {
+	vm := NewMachine(0xffff)
+	if _, err := vm.AttachDefaultBus(); err == nil {
+		t.Fatalf("expected error mapping banks larger than address space")
+	}
+	if _, err := vm.GetBank(memory.Flat); err == nil {
+		t.Fatalf("expected no bus to be attached")
+	}
+}
+
+func Test_Bus_ObservedBanks(t *testing.T) {
+	vm := NewMachine(255)
+	if _, err := vm.AttachDefaultBus(); err != nil {
+		t.Fatalf("unable to attach bus: %v", err)
+	}
+	var seen []memory.Access
+	vm.Observe(memory.RAM, memory.ObserverFunc(func(access memory.Access) {
+		seen = append(seen, access)
+	}))
+
+	bus, _ := vm.GetBank(memory.Flat)
+	bus.SetUint16(100, 161)
+	if len(seen) != 1 || seen[0].Bank != memory.RAM || seen[0].At != 100 || seen[0].New != 161 {
+		t.Fatalf("expected bus write to be observed on RAM, got %v", seen)
+	}
+}
+
+func Test_Bus_Protection(t *testing.T) {
+	vm := newSupervisedMachine(
+		packProgram(
+			instruction.MOV_LIT_AC.Pack(100),
+			instruction.MOV_LIT_MEM.Pack(161),
+		),
+		packProgram(
+			instruction.MOV_REG_REG.Pack(register.R2.AsUint16(), register.R6.AsUint16()),
+		),
+	)
+	vm.SetTrapHandler(cpu.TrapProtection, 200)
+	if _, err := vm.AttachDefaultBus(); err != nil {
+		t.Fatalf("unable to attach bus: %v", err)
+	}
+	vm.cpu.SetRegister(register.Bnk, uint16(memory.Flat))
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	if vm.cpu.GetRegister(register.R6) != 100 {
+		t.Fatalf("expected user access to bus to trap, got %d", vm.cpu.GetRegister(register.R6))
+	}
+
+	vm.Reset()
+	vm.Protect(memory.RAM, 100, 199, ReadOnly)
+	bus, _ := vm.GetBank(memory.Flat)
+	guarded := protectedMemory{mem: bus, kind: memory.Flat, protection: vm.protection, cpu: vm.cpu}
+	if err := guarded.SetUint16(100, 161); err == nil {
+		t.Fatalf("expected read-only RAM to stay protected through bus")
+	}
+	if err := guarded.SetUint16(50, 161); err != nil {
+		t.Fatalf("expected supervisor write through bus to writable RAM, got %v", err)
+	}
+	ram, _ := vm.GetBank(memory.RAM)
+	if x, _ := ram.GetUint16(100); x != 0 {
+		t.Fatalf("expected read-only RAM to stay unchanged, got %d", x)
+	}
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"fmt"
+	"the-machine/machine/internal"
+)
+
+const addressSpace = 0x10000
+
+// Region maps memory to a range of bus addresses, starting at offset 0 of the memory
+type Region struct {
+	Kind   MemoryType
+	From   Address
+	Size   int
+	Memory MemoryAccess
+}
+
+func (x Region) end() int {
+	return int(x.From) + x.Size
+}
+
+func (x Region) covers(at Address) bool {
+	return int(at) >= int(x.From) && int(at) < x.end()
+}
+
+func (x Region) overlaps(other Region) bool {
+	return int(x.From) < other.end() && int(other.From) < x.end()
+}
+
+// Bus is a flat address space decoded into regions
+type Bus struct {
+	regions []Region
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Attach maps memory to bus addresses from..from+size
+func (x *Bus) Attach(kind MemoryType, from Address, size int, mem MemoryAccess) error {
+	region := Region{Kind: kind, From: from, Size: size, Memory: mem}
+	if size <= 0 || region.end() > addressSpace {
+		return internal.Error(fmt.Sprintf("invalid %s region size %d at %d", kind, size, from), nil, internal.ErrorMemory)
+	}
+	for _, r := range x.regions {
+		if r.overlaps(region) {
+			return internal.Error(
+				fmt.Sprintf("%s region %d-%d overlaps %s region %d-%d", kind, from, region.end()-1, r.Kind, r.From, r.end()-1),
+				nil,
+				internal.ErrorMemory)
+		}
+	}
+	x.regions = append(x.regions, region)
+	return nil
+}
+
+func (x Bus) Regions() []Region {
+	return x.regions
+}
+
+// Resolve finds region backing bus address, and address within that region
+func (x Bus) Resolve(at Address) (Region, Address, error) {
+	for _, r := range x.regions {
+		if r.covers(at) {
+			return r, at - r.From, nil
+		}
+	}
+	return Region{}, 0, internal.Error(fmt.Sprintf("no device mapped at %d", at), nil, internal.ErrorMemory)
+}
+
+// Label names the device backing bus address
+func (x Bus) Label(at Address) string {
+	if r, _, err := x.Resolve(at); err == nil {
+		return r.Kind.String()
+	}
+	return "-"
+}
+
+// resolveUint16 requires both bytes to be backed by the same region,
+// so that devices get to see whole values
+func (x Bus) resolveUint16(at Address) (Region, Address, error) {
+	r, offset, err := x.Resolve(at)
+	if err != nil {
+		return r, offset, err
+	}
+	if !r.covers(at + 1) {
+		return r, offset, internal.Error(fmt.Sprintf("uint16 access at %d crosses %s region boundary", at, r.Kind), nil, internal.ErrorMemory)
+	}
+	return r, offset, nil
+}
+
+func (x Bus) GetByte(at Address) (byte, error) {
+	r, offset, err := x.Resolve(at)
+	if err != nil {
+		return 0, err
+	}
+	return r.Memory.GetByte(offset)
+}
+
+func (x Bus) GetUint16(at Address) (uint16, error) {
+	r, offset, err := x.resolveUint16(at)
+	if err != nil {
+		return 0, err
+	}
+	return r.Memory.GetUint16(offset)
+}
+
+func (x Bus) SetByte(at Address, value byte) error {
+	r, offset, err := x.Resolve(at)
+	if err != nil {
+		return err
+	}
+	return r.Memory.SetByte(offset, value)
+}
+
+func (x Bus) SetUint16(at Address, value uint16) error {
+	r, offset, err := x.resolveUint16(at)
+	if err != nil {
+		return err
+	}
+	return r.Memory.SetUint16(offset, value)
+}
@@ -0,0 +1,57 @@
+package memory
+
+import "testing"
+
+func Test_Bus_Attach(t *testing.T) {
+	bus := NewBus()
+
+	if err := bus.Attach(RAM, 0, 16, NewMemory(16)); err != nil {
+		t.Fatalf("expected success attaching RAM, got: %v", err)
+	}
+	if err := bus.Attach(ROM, 8, 16, NewMemory(16)); err == nil {
+		t.Fatalf("expected error attaching overlapping region")
+	}
+	if err := bus.Attach(ROM, 0xfff0, 32, NewMemory(32)); err == nil {
+		t.Fatalf("expected error attaching region past address space")
+	}
+	if err := bus.Attach(ROM, 16, 16, NewMemory(16)); err != nil {
+		t.Fatalf("expected success attaching adjacent region, got: %v", err)
+	}
+	if len(bus.Regions()) != 2 {
+		t.Fatalf("expected 2 regions, got %d", len(bus.Regions()))
+	}
+}
+
+func Test_Bus_Access(t *testing.T) {
+	ram := NewMemory(16)
+	rom := NewMemory(16)
+	bus := NewBus()
+	bus.Attach(RAM, 0, 16, ram)
+	bus.Attach(ROM, 16, 16, rom)
+
+	if err := bus.SetUint16(20, 1312); err != nil {
+		t.Fatalf("expected success setting bus address, got: %v", err)
+	}
+	if x, err := rom.GetUint16(4); err != nil || x != 1312 {
+		t.Fatalf("expected value in ROM at region offset, got %d and error %v", x, err)
+	}
+
+	ram.SetByte(3, 161)
+	if x, err := bus.GetByte(3); err != nil || x != 161 {
+		t.Fatalf("expected RAM value via bus, got %d and error %v", x, err)
+	}
+
+	if _, err := bus.GetUint16(15); err == nil {
+		t.Fatalf("expected error reading value across region boundary")
+	}
+	if _, err := bus.GetByte(32); err == nil {
+		t.Fatalf("expected error reading unmapped address")
+	}
+
+	if bus.Label(17) != ROM.String() {
+		t.Fatalf("expected ROM label, got %s", bus.Label(17))
+	}
+	if bus.Label(100) != "-" {
+		t.Fatalf("expected unmapped label, got %s", bus.Label(100))
+	}
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"the-machine/machine/internal"
+)
+
+// FileMemory is write-through memory persisted in a host file.
+// Every write is validated up front and lands in a single file write,
+// so a failing access never leaves a partial value behind.
+type FileMemory struct {
+	file *os.File
+	mem  Memory
+}
+
+// OpenFile opens memory backed by file at path, creating it
+// or zero-extending it to size as needed. Files larger than size
+// are refused, rather than cut short.
+func OpenFile(path string, size int) (*FileMemory, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, internal.Error(fmt.Sprintf("unable to open memory file %s", path), err, internal.ErrorMemory)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, internal.Error(fmt.Sprintf("unable to open memory file %s", path), err, internal.ErrorMemory)
+	}
+	if info.Size() > int64(size) {
+		file.Close()
+		return nil, internal.Error(fmt.Sprintf("memory file %s is larger than %d bytes: %d", path, size, info.Size()), nil, internal.ErrorMemory)
+	}
+	mem := make(Memory, size, size)
+	if _, err := io.ReadFull(file, mem); err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		file.Close()
+		return nil, internal.Error(fmt.Sprintf("unable to read memory file %s", path), err, internal.ErrorMemory)
+	}
+	if info.Size() < int64(size) {
+		if err := file.Truncate(int64(size)); err != nil {
+			file.Close()
+			return nil, internal.Error(fmt.Sprintf("unable to size memory file %s", path), err, internal.ErrorMemory)
+		}
+	}
+	return &FileMemory{file: file, mem: mem}, nil
+}
+
+func (x *FileMemory) IsStorage() bool {
+	return true
+}
+
+func (x *FileMemory) Size() int {
+	return x.mem.Size()
+}
+
+func (x *FileMemory) GetByte(at Address) (byte, error) {
+	return x.mem.GetByte(at)
+}
+
+func (x *FileMemory) GetUint16(at Address) (uint16, error) {
+	return x.mem.GetUint16(at)
+}
+
+func (x *FileMemory) SetByte(at Address, value byte) error {
+	return x.write(at, []byte{value})
+}
+
+func (x *FileMemory) SetUint16(at Address, value uint16) error {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, value)
+	return x.write(at, b)
+}
+
+func (x *FileMemory) write(at Address, data []byte) error {
+	if int(at)+len(data) > len(x.mem) {
+		return internal.Error(fmt.Sprintf("invalid memory access at %d (of %d): trying to write %d bytes", at, len(x.mem), len(data)), nil, internal.ErrorMemory)
+	}
+	if _, err := x.file.WriteAt(data, int64(at)); err != nil {
+		return internal.Error(fmt.Sprintf("unable to persist memory at %d", at), err, internal.ErrorMemory)
+	}
+	copy(x.mem[at:], data)
+	return nil
+}
+
+// Close flushes memory file to disk and releases it
+func (x *FileMemory) Close() error {
+	if err := x.file.Sync(); err != nil {
+		x.file.Close()
+		return internal.Error("unable to sync memory file", err, internal.ErrorMemory)
+	}
+	if err := x.file.Close(); err != nil {
+		return internal.Error("unable to close memory file", err, internal.ErrorMemory)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_FileMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+
+	mem, err := OpenFile(path, 16)
+	if err != nil {
+		t.Fatalf("unable to open file memory: %v", err)
+	}
+	if err := mem.SetUint16(4, 1312); err != nil {
+		t.Fatalf("expected success setting file memory, got: %v", err)
+	}
+	if err := mem.SetUint16(15, 161); err == nil {
+		t.Fatalf("expected error writing past file memory end")
+	}
+	mem.Close()
+
+	mem, err = OpenFile(path, 16)
+	if err != nil {
+		t.Fatalf("unable to reopen file memory: %v", err)
+	}
+	defer mem.Close()
+	if x, err := mem.GetUint16(4); err != nil || x != 1312 {
+		t.Fatalf("expected value to persist across runs, got %d and error %v", x, err)
+	}
+	if x, _ := mem.GetByte(15); x != 0 {
+		t.Fatalf("expected failed write to leave file intact, got %d", x)
+	}
+}
+
+func Test_FileMemory_Sizes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+	os.WriteFile(path, []byte{1, 2, 3, 4}, 0644)
+
+	if _, err := OpenFile(path, 2); err == nil {
+		t.Fatalf("expected error opening file larger than memory")
+	}
+	if data, _ := os.ReadFile(path); len(data) != 4 {
+		t.Fatalf("expected larger file to be left intact, got %v", data)
+	}
+
+	mem, err := OpenFile(path, 8)
+	if err != nil {
+		t.Fatalf("unable to open smaller file: %v", err)
+	}
+	mem.Close()
+	if data, _ := os.ReadFile(path); len(data) != 8 || data[3] != 4 {
+		t.Fatalf("expected smaller file to be zero-extended, got %v", data)
+	}
+}
@@ -21,6 +21,14 @@ func NewMemory(size int) MemoryAccess {
 	return &mem
 }
 
+func (mem Memory) IsStorage() bool {
+	return true
+}
+
+func (mem Memory) Size() int {
+	return len(mem)
+}
+
 func (mem Memory) GetByte(at Address) (byte, error) {
 	addr := int(at)
 	if addr < len(mem) {
@@ -70,3 +78,14 @@ func (mem *Memory) SetUint16(at Address, value uint16) error {
 	}
 	return nil
 }
+
+// AtomicAccess is implemented by memory able to run a sequence
+// of accesses without interference from other cores
+type AtomicAccess interface {
+	Atomically(func(MemoryAccess) error) error
+}
+
+// Labeler is implemented by memory able to name what backs an address
+type Labeler interface {
+	Label(Address) string
+}
@@ -0,0 +1,154 @@
+package memory
+
+// Operation is the kind of observed memory access
+type Operation uint8
+
+const (
+	ReadByte    Operation = 0
+	ReadUint16  Operation = iota
+	WriteByte   Operation = iota
+	WriteUint16 Operation = iota
+	Fetch       Operation = iota
+)
+
+func (x Operation) String() string {
+	switch x {
+	case ReadByte:
+		return "read byte"
+	case ReadUint16:
+		return "read uint16"
+	case WriteByte:
+		return "write byte"
+	case WriteUint16:
+		return "write uint16"
+	case Fetch:
+		return "fetch"
+	default:
+		return "unknown operation"
+	}
+}
+
+func (x Operation) IsWrite() bool {
+	return x == WriteByte || x == WriteUint16
+}
+
+// Access describes a successful memory access.
+// Reads report the value read as both old and new.
+// Writes to devices leave old value unknown, as reading it could have side effects.
+type Access struct {
+	Bank      MemoryType
+	Operation Operation
+	At        Address
+	Old       uint16
+	OldKnown  bool
+	New       uint16
+}
+
+// Storage is implemented by memory that can be read without side effects,
+// unlike devices, whose reads may consume input or block
+type Storage interface {
+	IsStorage() bool
+}
+
+type Observer interface {
+	Observe(Access)
+}
+
+// ObserverFunc adapts a function to Observer
+type ObserverFunc func(Access)
+
+func (f ObserverFunc) Observe(access Access) {
+	f(access)
+}
+
+// Observed decorates memory, notifying observers of each access
+type Observed struct {
+	mem       MemoryAccess
+	bank      MemoryType
+	observers []Observer
+	fetching  bool
+	storage   bool
+}
+
+func NewObserved(bank MemoryType, mem MemoryAccess) *Observed {
+	return &Observed{mem: mem, bank: bank, storage: isStorage(mem)}
+}
+
+func isStorage(mem MemoryAccess) bool {
+	storage, ok := Unwrap(mem).(Storage)
+	return ok && storage.IsStorage()
+}
+
+func (x *Observed) AddObserver(observer Observer) {
+	x.observers = append(x.observers, observer)
+}
+
+// Fetching gives a view of memory reporting reads as instruction fetches
+func (x *Observed) Fetching() *Observed {
+	return &Observed{mem: x.mem, bank: x.bank, observers: x.observers, fetching: true, storage: x.storage}
+}
+
+func (x *Observed) Unwrap() MemoryAccess {
+	return x.mem
+}
+
+func (x *Observed) notify(op Operation, at Address, old uint16, oldKnown bool, new uint16) {
+	if x.fetching && !op.IsWrite() {
+		op = Fetch
+	}
+	access := Access{Bank: x.bank, Operation: op, At: at, Old: old, OldKnown: oldKnown, New: new}
+	for _, observer := range x.observers {
+		observer.Observe(access)
+	}
+}
+
+func (x *Observed) GetByte(at Address) (byte, error) {
+	value, err := x.mem.GetByte(at)
+	if err == nil {
+		x.notify(ReadByte, at, uint16(value), true, uint16(value))
+	}
+	return value, err
+}
+
+func (x *Observed) GetUint16(at Address) (uint16, error) {
+	value, err := x.mem.GetUint16(at)
+	if err == nil {
+		x.notify(ReadUint16, at, value, true, value)
+	}
+	return value, err
+}
+
+func (x *Observed) SetByte(at Address, value byte) error {
+	var old byte
+	if x.storage {
+		old, _ = x.mem.GetByte(at)
+	}
+	if err := x.mem.SetByte(at, value); err != nil {
+		return err
+	}
+	x.notify(WriteByte, at, uint16(old), x.storage, uint16(value))
+	return nil
+}
+
+func (x *Observed) SetUint16(at Address, value uint16) error {
+	var old uint16
+	if x.storage {
+		old, _ = x.mem.GetUint16(at)
+	}
+	if err := x.mem.SetUint16(at, value); err != nil {
+		return err
+	}
+	x.notify(WriteUint16, at, old, x.storage, value)
+	return nil
+}
+
+// Unwrap peels off decorators, such as Observed, down to the backing memory
+func Unwrap(mem MemoryAccess) MemoryAccess {
+	for {
+		wrapper, ok := mem.(interface{ Unwrap() MemoryAccess })
+		if !ok {
+			return mem
+		}
+		mem = wrapper.Unwrap()
+	}
+}
@@ -0,0 +1,30 @@
+package memory
+
+import "testing"
+
+func Test_Observed(t *testing.T) {
+	var seen []Access
+	mem := NewObserved(RAM, NewMemory(16))
+	mem.AddObserver(ObserverFunc(func(access Access) {
+		seen = append(seen, access)
+	}))
+
+	mem.SetUint16(4, 1312)
+	mem.SetUint16(4, 161)
+	mem.GetByte(4)
+	mem.SetByte(32, 1)
+
+	if len(seen) != 3 {
+		t.Fatalf("expected 3 successful accesses observed, got %d", len(seen))
+	}
+	if seen[1].Operation != WriteUint16 || seen[1].Old != 1312 || !seen[1].OldKnown || seen[1].New != 161 || seen[1].At != 4 || seen[1].Bank != RAM {
+		t.Fatalf("unexpected write observed: %v", seen[1])
+	}
+	if seen[2].Operation != ReadByte || seen[2].New != 161 {
+		t.Fatalf("unexpected read observed: %v", seen[2])
+	}
+
+	if _, ok := Unwrap(mem).(*Memory); !ok {
+		t.Fatalf("expected to unwrap to backing memory")
+	}
+}
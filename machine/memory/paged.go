@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"the-machine/machine/internal"
+)
+
+const (
+	pageSize  = 256
+	tableSize = 16
+)
+
+// page is owned by the Paged memory whose generation matches,
+// anyone else has to copy it before writing
+type page struct {
+	data       [pageSize]byte
+	generation uint64
+}
+
+// table groups pages, and is owned and copied the same way
+type table struct {
+	pages      [tableSize]*page
+	generation uint64
+}
+
+// directory is the root of page tables, owned and copied the same way
+type directory struct {
+	tables     []*table
+	generation uint64
+}
+
+var generations uint64
+
+func nextGeneration() uint64 {
+	return atomic.AddUint64(&generations, 1)
+}
+
+// Paged is copy-on-write memory: snapshots share pages until either side writes.
+// Pages never written to are not allocated at all.
+// Taking a snapshot is O(1), the first write to a page after it copies
+// only the page and the tables leading to it.
+type Paged struct {
+	dir        *directory
+	size       int
+	generation uint64
+}
+
+func NewPagedMemory(size int) *Paged {
+	pages := (size + pageSize - 1) / pageSize
+	generation := nextGeneration()
+	return &Paged{
+		dir:        &directory{tables: make([]*table, (pages+tableSize-1)/tableSize), generation: generation},
+		size:       size,
+		generation: generation,
+	}
+}
+
+func (x *Paged) IsStorage() bool {
+	return true
+}
+
+func (x *Paged) Size() int {
+	return x.size
+}
+
+// Snapshot returns a copy of memory in its current state,
+// sharing everything until either side writes
+func (x *Paged) Snapshot() *Paged {
+	x.generation = nextGeneration()
+	return &Paged{dir: x.dir, size: x.size, generation: nextGeneration()}
+}
+
+// Restore puts memory back into snapshot state, leaving snapshot intact
+func (x *Paged) Restore(from *Paged) error {
+	if from.size != x.size {
+		return internal.Error(fmt.Sprintf("unable to restore %d bytes of memory from %d", x.size, from.size), nil, internal.ErrorMemory)
+	}
+	from.generation = nextGeneration()
+	x.dir = from.dir
+	x.generation = nextGeneration()
+	return nil
+}
+
+func (x *Paged) check(at Address, length int) error {
+	if int(at)+length > x.size {
+		return internal.Error(fmt.Sprintf("invalid memory access at %d (of %d)", at, x.size), nil, internal.ErrorMemory)
+	}
+	return nil
+}
+
+func (x *Paged) page(at Address) *page {
+	idx := int(at) / pageSize
+	t := x.dir.tables[idx/tableSize]
+	if t == nil {
+		return nil
+	}
+	return t.pages[idx%tableSize]
+}
+
+func (x *Paged) get(at Address) byte {
+	p := x.page(at)
+	if p == nil {
+		return 0
+	}
+	return p.data[int(at)%pageSize]
+}
+
+func (x *Paged) set(at Address, value byte) {
+	if x.dir.generation != x.generation {
+		tables := make([]*table, len(x.dir.tables))
+		copy(tables, x.dir.tables)
+		x.dir = &directory{tables: tables, generation: x.generation}
+	}
+	idx := int(at) / pageSize
+	t := x.dir.tables[idx/tableSize]
+	if t == nil {
+		t = &table{generation: x.generation}
+		x.dir.tables[idx/tableSize] = t
+	} else if t.generation != x.generation {
+		owned := *t
+		owned.generation = x.generation
+		t = &owned
+		x.dir.tables[idx/tableSize] = t
+	}
+	p := t.pages[idx%tableSize]
+	if p == nil {
+		p = &page{generation: x.generation}
+		t.pages[idx%tableSize] = p
+	} else if p.generation != x.generation {
+		owned := *p
+		owned.generation = x.generation
+		p = &owned
+		t.pages[idx%tableSize] = p
+	}
+	p.data[int(at)%pageSize] = value
+}
+
+func (x *Paged) GetByte(at Address) (byte, error) {
+	if err := x.check(at, 1); err != nil {
+		return 0, err
+	}
+	return x.get(at), nil
+}
+
+func (x *Paged) GetUint16(at Address) (uint16, error) {
+	if err := x.check(at, 2); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16([]byte{x.get(at), x.get(at + 1)}), nil
+}
+
+func (x *Paged) SetByte(at Address, value byte) error {
+	if err := x.check(at, 1); err != nil {
+		return internal.Error(fmt.Sprintf("unable to set byte %#02x", value), err, internal.ErrorMemory)
+	}
+	x.set(at, value)
+	return nil
+}
+
+func (x *Paged) SetUint16(at Address, value uint16) error {
+	if err := x.check(at, 2); err != nil {
+		return internal.Error(fmt.Sprintf("unable to set %d", value), err, internal.ErrorMemory)
+	}
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, value)
+	x.set(at, b[0])
+	x.set(at+1, b[1])
+	return nil
+}
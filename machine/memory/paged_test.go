@@ -0,0 +1,87 @@
+package memory
+
+import "testing"
+
+func Test_Paged_MatchesMemory(t *testing.T) {
+	flat := NewMemory(1000)
+	paged := NewPagedMemory(1000)
+
+	for _, at := range []Address{0, 255, 511, 998} {
+		flat.SetUint16(at, 1312+uint16(at))
+		paged.SetUint16(at, 1312+uint16(at))
+	}
+	for at := Address(0); at < 999; at++ {
+		x, _ := flat.GetUint16(at)
+		y, err := paged.GetUint16(at)
+		if err != nil || x != y {
+			t.Fatalf("expected paged memory to match flat memory at %d: %d vs %d (%v)", at, x, y, err)
+		}
+	}
+	if _, err := paged.GetUint16(999); err == nil {
+		t.Fatalf("expected error reading past paged memory end")
+	}
+	if err := paged.SetByte(1000, 1); err == nil {
+		t.Fatalf("expected error writing past paged memory end")
+	}
+}
+
+func Test_Paged_Snapshot(t *testing.T) {
+	mem := NewPagedMemory(0xffff)
+	mem.SetUint16(13, 161)
+	mem.SetUint16(1000, 12)
+
+	snapshot := mem.Snapshot()
+	mem.SetUint16(13, 1312)
+
+	if x, _ := snapshot.GetUint16(13); x != 161 {
+		t.Fatalf("expected snapshot to keep old value, got %d", x)
+	}
+	if x, _ := mem.GetUint16(13); x != 1312 {
+		t.Fatalf("expected memory to get new value, got %d", x)
+	}
+	if mem.page(0) == snapshot.page(0) {
+		t.Fatalf("expected written page to be copied")
+	}
+	if mem.page(1000) != snapshot.page(1000) {
+		t.Fatalf("expected untouched page to be shared")
+	}
+
+	snapshot.SetUint16(1000, 27)
+	if x, _ := mem.GetUint16(1000); x != 12 {
+		t.Fatalf("expected writes to snapshot to leave memory alone, got %d", x)
+	}
+}
+
+func Test_Paged_Restore(t *testing.T) {
+	mem := NewPagedMemory(0xffff)
+	mem.SetUint16(13, 161)
+
+	snapshot := mem.Snapshot()
+	if mem.dir != snapshot.dir {
+		t.Fatalf("expected snapshot to share pages without copying")
+	}
+	mem.SetUint16(13, 1312)
+	mem.SetUint16(0x8000, 12)
+
+	if err := mem.Restore(snapshot); err != nil {
+		t.Fatalf("unable to restore snapshot: %v", err)
+	}
+	if x, _ := mem.GetUint16(13); x != 161 {
+		t.Fatalf("expected restored value, got %d", x)
+	}
+	if x, _ := mem.GetUint16(0x8000); x != 0 {
+		t.Fatalf("expected write after snapshot to be undone, got %d", x)
+	}
+
+	mem.SetUint16(13, 27)
+	snapshot.SetUint16(100, 1)
+	if x, _ := snapshot.GetUint16(13); x != 161 {
+		t.Fatalf("expected writes after restore to leave snapshot alone, got %d", x)
+	}
+	if x, _ := mem.GetUint16(13); x != 27 {
+		t.Fatalf("expected writes to snapshot to leave memory alone, got %d", x)
+	}
+	if err := mem.Restore(NewPagedMemory(16)); err == nil {
+		t.Fatalf("expected error restoring snapshot of different size")
+	}
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"fmt"
+	"the-machine/machine/internal"
+)
+
+// ErrWriteProtect is the cause of every rejected write to read-only memory
+var ErrWriteProtect = internal.Error("memory is write-protected", nil, internal.ErrorMemory)
+
+// Flasher is implemented by memory that can only be written
+// through explicit programming, such as ROM
+type Flasher interface {
+	Flash(at Address, data []byte) error
+}
+
+// Rom is read-only memory, writable only by flashing
+type Rom struct {
+	mem *Paged
+}
+
+func NewRom(size int) *Rom {
+	return &Rom{mem: NewPagedMemory(size)}
+}
+
+// Snapshot returns a copy of ROM in its current state
+func (x *Rom) Snapshot() *Rom {
+	return &Rom{mem: x.mem.Snapshot()}
+}
+
+// Restore puts ROM back into snapshot state
+func (x *Rom) Restore(from *Rom) error {
+	return x.mem.Restore(from.mem)
+}
+
+func (x Rom) IsStorage() bool {
+	return true
+}
+
+func (x Rom) Size() int {
+	return x.mem.Size()
+}
+
+func (x Rom) GetByte(at Address) (byte, error) {
+	return x.mem.GetByte(at)
+}
+
+func (x Rom) GetUint16(at Address) (uint16, error) {
+	return x.mem.GetUint16(at)
+}
+
+func (x Rom) SetByte(at Address, value byte) error {
+	return internal.Error(fmt.Sprintf("refusing to set byte %#02x at %d", value, at), ErrWriteProtect, internal.ErrorMemory)
+}
+
+func (x Rom) SetUint16(at Address, value uint16) error {
+	return internal.Error(fmt.Sprintf("refusing to set %d at %d", value, at), ErrWriteProtect, internal.ErrorMemory)
+}
+
+// Flash programs data at address, all or nothing
+func (x *Rom) Flash(at Address, data []byte) error {
+	if int(at)+len(data) > x.mem.Size() {
+		return internal.Error(fmt.Sprintf("unable to flash %d bytes at %d (of %d)", len(data), at, x.mem.Size()), nil, internal.ErrorMemory)
+	}
+	for idx, b := range data {
+		x.mem.set(at+Address(idx), b)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_Rom(t *testing.T) {
+	rom := NewRom(16)
+
+	if err := rom.Flash(2, []byte{13, 161}); err != nil {
+		t.Fatalf("expected success flashing ROM, got: %v", err)
+	}
+	if x, err := rom.GetByte(3); err != nil || x != 161 {
+		t.Fatalf("expected flashed value, got %d and error %v", x, err)
+	}
+
+	if err := rom.SetByte(2, 12); !errors.Is(err, ErrWriteProtect) {
+		t.Fatalf("expected write-protect error setting byte, got: %v", err)
+	}
+	if err := rom.SetUint16(2, 1312); !errors.Is(err, ErrWriteProtect) {
+		t.Fatalf("expected write-protect error setting uint16, got: %v", err)
+	}
+	if x, _ := rom.GetByte(2); x != 13 {
+		t.Fatalf("expected ROM to stay unchanged, got %d", x)
+	}
+
+	if err := rom.Flash(15, []byte{1, 2}); err == nil {
+		t.Fatalf("expected error flashing past ROM end")
+	}
+	if x, _ := rom.GetByte(15); x != 0 {
+		t.Fatalf("expected failed flash to leave ROM unchanged, got %d", x)
+	}
+}
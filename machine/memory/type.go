@@ -5,10 +5,19 @@ import "fmt"
 type MemoryType uint8
 
 const (
-	RAM       MemoryType = 0
-	ROM       MemoryType = iota
-	DeviceVGA MemoryType = iota
-	DeviceIO  MemoryType = iota
+	RAM            MemoryType = 0
+	ROM            MemoryType = iota
+	DeviceVGA      MemoryType = iota
+	DeviceIO       MemoryType = iota
+	Flat           MemoryType = iota
+	Persisted      MemoryType = iota
+	DeviceKeyboard MemoryType = iota
+	DeviceTimer    MemoryType = iota
+	DeviceDisk     MemoryType = iota
+	DeviceNet      MemoryType = iota
+
+	// DeviceCustom is the first memory type available to registered devices
+	DeviceCustom MemoryType = 16
 )
 
 func (x MemoryType) String() string {
@@ -21,7 +30,22 @@ func (x MemoryType) String() string {
 		return "VGA"
 	case DeviceIO:
 		return "IO"
+	case Flat:
+		return "BUS"
+	case Persisted:
+		return "FILE"
+	case DeviceKeyboard:
+		return "KBD"
+	case DeviceTimer:
+		return "TMR"
+	case DeviceDisk:
+		return "DSK"
+	case DeviceNet:
+		return "NET"
 	default:
+		if x >= DeviceCustom {
+			return fmt.Sprintf("DEV#%d", x)
+		}
 		return fmt.Sprintf("unknown memory type: %d", x)
 	}
 }
@@ -0,0 +1,212 @@
+package machine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"the-machine/machine/cpu"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+// Pages are 256 bytes, so the high address byte is the page number
+// and the page table holds 256 uint16 entries, starting at Ptb in RAM.
+// Page table entry is physical page number in the high byte, flags in the low.
+const (
+	PageSize  = 256
+	PageCount = 256
+)
+
+const (
+	PagePresent  uint16 = 1 << iota
+	PageWritable uint16 = 1 << iota
+	PageUser     uint16 = 1 << iota
+)
+
+// PageEntry packs page table entry for physical page with flags
+func PageEntry(physical byte, flags uint16) uint16 {
+	return uint16(physical)<<8 | (flags & 0xff)
+}
+
+type tlbEntry struct {
+	page  byte
+	entry uint16
+}
+
+// MMU translates user mode virtual addresses through page tables in RAM.
+// Supervisor mode accesses are not translated.
+type MMU struct {
+	tlb     []tlbEntry
+	size    int
+	next    int
+	ptb     uint16
+	loaded  bool
+	hits    uint64
+	misses  uint64
+	flushes uint64
+}
+
+func NewMMU(tlbSize int) *MMU {
+	return &MMU{tlb: make([]tlbEntry, 0, tlbSize), size: tlbSize}
+}
+
+// Flush drops all cached translations
+func (x *MMU) Flush() {
+	x.tlb = x.tlb[:0]
+	x.next = 0
+	x.flushes++
+}
+
+func (x MMU) Stats() (hits uint64, misses uint64, flushes uint64) {
+	return x.hits, x.misses, x.flushes
+}
+
+func (x *MMU) lookup(ram memory.MemoryAccess, ptb uint16, page byte) (uint16, error) {
+	if x.loaded && ptb != x.ptb {
+		// Switching page tables invalidates cached translations
+		x.Flush()
+	}
+	x.ptb = ptb
+	x.loaded = true
+	for _, cached := range x.tlb {
+		if cached.page == page {
+			x.hits++
+			return cached.entry, nil
+		}
+	}
+	x.misses++
+
+	entry, err := ram.GetUint16(memory.Address(ptb) + memory.Address(page)*2)
+	if err != nil {
+		return 0, internal.Error(fmt.Sprintf("unable to read page table entry %d at %d", page, ptb), err, internal.ErrorMemory)
+	}
+	if entry&PagePresent == 0 {
+		return entry, nil
+	}
+
+	// FIFO replacement
+	if len(x.tlb) < x.size {
+		x.tlb = append(x.tlb, tlbEntry{page: page, entry: entry})
+	} else if x.size > 0 {
+		x.tlb[x.next] = tlbEntry{page: page, entry: entry}
+		x.next = (x.next + 1) % x.size
+	}
+	return entry, nil
+}
+
+func (x *MMU) translate(ram memory.MemoryAccess, ptb uint16, at memory.Address, write bool) (memory.Address, error) {
+	page := byte(at >> 8)
+	entry, err := x.lookup(ram, ptb, page)
+	if err != nil {
+		return 0, err
+	}
+	if entry&PagePresent == 0 {
+		return 0, cpu.RaiseTrap(cpu.TrapPageFault, uint16(at), "page not present")
+	}
+	if entry&PageUser == 0 {
+		return 0, cpu.RaiseTrap(cpu.TrapPageFault, uint16(at), "page is supervisor-only")
+	}
+	if write && entry&PageWritable == 0 {
+		return 0, cpu.RaiseTrap(cpu.TrapPageFault, uint16(at), "page is read-only")
+	}
+	return memory.Address(entry&0xff00) | (at & 0xff), nil
+}
+
+// translatedMemory resolves guest addresses through MMU in user mode
+type translatedMemory struct {
+	mem memory.MemoryAccess
+	ram memory.MemoryAccess
+	mmu *MMU
+	cpu *cpu.Cpu
+}
+
+func (x translatedMemory) resolve(at memory.Address, write bool) (memory.Address, error) {
+	if x.cpu.GetMode() == cpu.Supervisor {
+		return at, nil
+	}
+	return x.mmu.translate(x.ram, x.cpu.GetRegister(register.Ptb), at, write)
+}
+
+func (x translatedMemory) GetByte(at memory.Address) (byte, error) {
+	addr, err := x.resolve(at, false)
+	if err != nil {
+		return 0, err
+	}
+	return x.mem.GetByte(addr)
+}
+
+func (x translatedMemory) GetUint16(at memory.Address) (uint16, error) {
+	hi, err := x.GetByte(at)
+	if err != nil {
+		return 0, err
+	}
+	lo, err := x.GetByte(at + 1)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16([]byte{hi, lo}), nil
+}
+
+func (x translatedMemory) SetByte(at memory.Address, value byte) error {
+	addr, err := x.resolve(at, true)
+	if err != nil {
+		return err
+	}
+	return x.mem.SetByte(addr, value)
+}
+
+func (x translatedMemory) SetUint16(at memory.Address, value uint16) error {
+	// Resolve both bytes first, so faults leave memory untouched
+	if _, err := x.resolve(at, true); err != nil {
+		return err
+	}
+	if _, err := x.resolve(at+1, true); err != nil {
+		return err
+	}
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, value)
+	if err := x.SetByte(at, b[0]); err != nil {
+		return err
+	}
+	return x.SetByte(at+1, b[1])
+}
+
+// SetMMU enables address translation for RAM and ROM in user mode,
+// nil disables it
+func (vm *Machine) SetMMU(mmu *MMU) {
+	vm.mmu = mmu
+}
+
+func (vm Machine) GetMMU() *MMU {
+	return vm.mmu
+}
+
+// translate wraps bank memory with MMU, if enabled for the bank
+func (vm *Machine) translate(kind memory.MemoryType, mem memory.MemoryAccess) (memory.MemoryAccess, error) {
+	if vm.mmu == nil {
+		return mem, nil
+	}
+	if vm.cpu.TranslationsInvalidated() {
+		vm.mmu.Flush()
+	}
+	if kind != memory.RAM && kind != memory.ROM {
+		return mem, nil
+	}
+	ram, err := vm.getMemory(memory.RAM)
+	if err != nil {
+		return mem, internal.Error("unable to access page tables", err, internal.ErrorMemory)
+	}
+	return translatedMemory{mem: mem, ram: ram, mmu: vm.mmu, cpu: vm.cpu}, nil
+}
+
+// SetMMU enables address translation on all cores, each with its own
+// MMU made by factory, nil factory disables it
+func (vm *MultiCore) SetMMU(factory func() *MMU) {
+	for _, core := range vm.cores {
+		if factory == nil {
+			core.SetMMU(nil)
+			continue
+		}
+		core.SetMMU(factory())
+	}
+}
@@ -0,0 +1,196 @@
+package machine
+
+import (
+	"testing"
+	"the-machine/machine/cpu"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+func Test_MMU_Translate(t *testing.T) {
+	ram := memory.NewMemory(1024)
+	ram.SetUint16(512+2*1, PageEntry(3, PagePresent|PageUser))
+	mmu := NewMMU(2)
+
+	if at, err := mmu.translate(ram, 512, 0x0112, false); err != nil || at != 0x0312 {
+		t.Fatalf("expected 0x112 to translate to 0x312, got %#04x and error %v", at, err)
+	}
+	if at, err := mmu.translate(ram, 512, 0x01ff, false); err != nil || at != 0x03ff {
+		t.Fatalf("expected 0x1ff to translate to 0x3ff, got %#04x and error %v", at, err)
+	}
+	if hits, misses, _ := mmu.Stats(); hits != 1 || misses != 1 {
+		t.Fatalf("expected one TLB hit and one miss, got %d and %d", hits, misses)
+	}
+
+	if _, err := mmu.translate(ram, 512, 0x0112, true); err == nil {
+		t.Fatalf("expected page fault writing to read-only page")
+	}
+	if _, err := mmu.translate(ram, 512, 0x0212, false); err == nil {
+		t.Fatalf("expected page fault reading page not present")
+	}
+
+	mmu.translate(ram, 514, 0x0012, false)
+	if _, _, flushes := mmu.Stats(); flushes != 1 {
+		t.Fatalf("expected TLB flush on page table switch, got %d", flushes)
+	}
+	if _, misses, _ := mmu.Stats(); misses != 3 {
+		t.Fatalf("expected TLB miss after flush, got %d", misses)
+	}
+}
+
+func Test_MMU_SeparateAddressSpaces(t *testing.T) {
+	vm := NewMachine(2048)
+	vm.SetMMU(NewMMU(4))
+	ram, _ := vm.getMemory(memory.RAM)
+
+	task := packProgram(
+		instruction.MOV_LIT_AC.Pack(0x100+12),
+		instruction.MOV_REG_MEM.Pack(register.R3.AsUint16()),
+	)
+	tables := map[uint16][]byte{
+		0x400: {1, 2}, // task A: code in page 1, data in page 2
+		0x480: {3, 4}, // task B: code in page 3, data in page 4
+	}
+	for ptb, pages := range tables {
+		ram.SetUint16(memory.Address(ptb), PageEntry(pages[0], PagePresent|PageUser))
+		ram.SetUint16(memory.Address(ptb+2), PageEntry(pages[1], PagePresent|PageWritable|PageUser))
+		vm.LoadProgram(memory.Address(pages[0])*PageSize, task)
+	}
+
+	for ptb, pages := range tables {
+		vm.Reset()
+		vm.cpu.SetRegister(register.Ptb, ptb)
+		vm.cpu.SetRegister(register.R3, uint16(pages[1]))
+		vm.cpu.SetMode(cpu.User)
+
+		if steps, err := run(vm); err != nil {
+			t.Fatalf("machine stuck (%d) or error running task: %v", steps, err)
+		}
+		at := memory.Address(pages[1])*PageSize + 12
+		if value, _ := ram.GetUint16(at); value != uint16(pages[1]) {
+			t.Fatalf("expected task to write %d to physical %d, got %d", pages[1], at, value)
+		}
+	}
+}
+
+func Test_MMU_PageFault(t *testing.T) {
+	vm := NewMachine(2048)
+	vm.SetMMU(NewMMU(4))
+	ram, _ := vm.getMemory(memory.RAM)
+	ram.SetUint16(0x400, PageEntry(1, PagePresent|PageUser))
+
+	vm.LoadProgram(0x100, packProgram(
+		instruction.MOV_LIT_R1.Pack(0x300+1),
+		instruction.MOV_MEM_REG.Pack(register.R1.AsUint16(), register.R3.AsUint16()),
+	))
+	vm.LoadProgram(0x200, packProgram(
+		instruction.MOV_REG_REG.Pack(register.R1.AsUint16(), register.R5.AsUint16()),
+		instruction.MOV_REG_REG.Pack(register.R2.AsUint16(), register.R6.AsUint16()),
+	))
+	vm.SetTrapHandler(cpu.TrapPageFault, 0x200)
+
+	vm.cpu.SetRegister(register.Ptb, 0x400)
+	vm.cpu.SetMode(cpu.User)
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	if vm.cpu.GetRegister(register.R5) != uint16(cpu.TrapPageFault) {
+		t.Fatalf("expected page fault trap, got %d", vm.cpu.GetRegister(register.R5))
+	}
+	if vm.cpu.GetRegister(register.R6) != 0x301 {
+		t.Fatalf("expected faulting address 0x301, got %#04x", vm.cpu.GetRegister(register.R6))
+	}
+}
+
+func Test_MMU_DemandPaging(t *testing.T) {
+	vm := NewMachine(2048)
+	vm.SetMMU(NewMMU(4))
+	ram, _ := vm.getMemory(memory.RAM)
+	ram.SetUint16(0x300, PageEntry(1, PagePresent|PageUser))
+	ram.SetUint16(0x201, 1312)
+
+	vm.LoadProgram(0x100, packProgram(
+		instruction.MOV_LIT_R1.Pack(0x300+1),
+		instruction.MOV_MEM_REG.Pack(register.R1.AsUint16(), register.R3.AsUint16()),
+	))
+	// Map faulting page 3 to physical page 2 and retry
+	vm.LoadProgram(0x200, packStatements(instruction.SYSRET,
+		instruction.MOV_LIT_AC.Pack(0x300+3*2),
+		instruction.MOV_LIT_MEM.Pack(PageEntry(2, PagePresent|PageUser)),
+	))
+	vm.SetTrapHandler(cpu.TrapPageFault, 0x200)
+
+	vm.cpu.SetRegister(register.Ptb, 0x300)
+	vm.cpu.SetMode(cpu.User)
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	if vm.cpu.GetRegister(register.R3) != 1312 {
+		t.Fatalf("expected retried load to read mapped page, got %d", vm.cpu.GetRegister(register.R3))
+	}
+}
+
+func Test_MMU_FlushAfterPageTableEdit(t *testing.T) {
+	vm := NewMachine(2048)
+	vm.SetMMU(NewMMU(4))
+	ram, _ := vm.getMemory(memory.RAM)
+
+	// Supervisor entry points: resume task as is at 0, flush TLB first at 0x20
+	vm.LoadProgram(0, packProgram(instruction.ENTER_USER.Pack(register.R4.AsUint16())))
+	vm.LoadProgram(0x20, packProgram(
+		instruction.TLB_FLUSH.Pack(0),
+		instruction.ENTER_USER.Pack(register.R4.AsUint16()),
+	))
+	vm.LoadProgram(PageSize, packProgram(
+		instruction.MOV_LIT_AC.Pack(0x100+12),
+		instruction.MOV_REG_MEM.Pack(register.R3.AsUint16()),
+	))
+	ram.SetUint16(0x400, PageEntry(1, PagePresent|PageUser))
+	ram.SetUint16(0x402, PageEntry(2, PagePresent|PageWritable|PageUser))
+
+	resume := func(entry uint16, value uint16) {
+		vm.Reset()
+		vm.cpu.SetRegister(register.Ptb, 0x400)
+		vm.cpu.SetRegister(register.Ip, entry)
+		vm.cpu.SetRegister(register.R3, value)
+		if steps, err := run(vm); err != nil {
+			t.Fatalf("machine stuck (%d) or error running task: %v", steps, err)
+		}
+	}
+
+	resume(0, 1)
+	ram.SetUint16(0x402, PageEntry(3, PagePresent|PageWritable|PageUser))
+	resume(0, 2)
+	if value, _ := ram.GetUint16(2*PageSize + 12); value != 2 {
+		t.Fatalf("expected stale translation without flush, got %d", value)
+	}
+
+	resume(0x20, 3)
+	if value, _ := ram.GetUint16(3*PageSize + 12); value != 3 {
+		t.Fatalf("expected page table edit to take effect after flush, got %d", value)
+	}
+	if value, _ := ram.GetUint16(2*PageSize + 12); value != 2 {
+		t.Fatalf("expected old page to be left alone after flush, got %d", value)
+	}
+
+	vm.Reset()
+	vm.cpu.SetMode(cpu.User)
+	if err := vm.execute(instruction.Descriptors[instruction.TLB_FLUSH]); err == nil {
+		t.Fatalf("expected TLB flush to be supervisor-only")
+	}
+}
+
+func Test_MMU_MultiCore(t *testing.T) {
+	vm := NewMultiCore(2, 255, RoundRobin)
+	vm.SetMMU(func() *MMU { return NewMMU(4) })
+	first, _ := vm.Core(0)
+	second, _ := vm.Core(1)
+	if first.GetMMU() == nil || first.GetMMU() == second.GetMMU() {
+		t.Fatalf("expected each core to get its own MMU")
+	}
+	vm.SetMMU(nil)
+	if first.GetMMU() != nil || second.GetMMU() != nil {
+		t.Fatalf("expected nil factory to disable MMU")
+	}
+}
@@ -0,0 +1,223 @@
+package machine
+
+import (
+	"fmt"
+	"sync"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+type Scheduling uint8
+
+const (
+	RoundRobin Scheduling = 0
+	Parallel   Scheduling = iota
+)
+
+// MultiCore runs several cores, each with its own registers and stack,
+// against one shared memory map. Core 0 boots at program start, the
+// others wait to be started by guest code (see instruction.START_CORE).
+type MultiCore struct {
+	cores      []*Machine
+	running    []bool
+	memory     MemoryMap
+	memoryLock *sync.Mutex
+	scheduling Scheduling
+	limit      int  // Tick limit of the current parallel run
+	active     bool // Parallel run in progress
+	ticks      int
+	lock       *sync.Mutex
+	group      *sync.WaitGroup
+	err        error
+}
+
+func NewMultiCore(cores int, memsize int, scheduling Scheduling) *MultiCore {
+	vm := &MultiCore{
+		cores:      make([]*Machine, cores, cores),
+		running:    make([]bool, cores, cores),
+		memory:     NewMemoryMap(memsize, memsize),
+		memoryLock: &sync.Mutex{},
+		scheduling: scheduling,
+		lock:       &sync.Mutex{},
+		group:      &sync.WaitGroup{},
+	}
+	for id := 0; id < cores; id++ {
+		vm.cores[id] = newCore(uint16(id), vm, vm.memory, vm.memoryLock)
+	}
+	vm.running[0] = true
+	return vm
+}
+
+func (vm *MultiCore) Core(id uint16) (*Machine, error) {
+	if int(id) >= len(vm.cores) {
+		return nil, internal.Error(fmt.Sprintf("unknown core %d (of %d)", id, len(vm.cores)), nil, internal.ErrorCore)
+	}
+	return vm.cores[id], nil
+}
+
+func (vm *MultiCore) LoadProgram(at memory.Address, program []byte) error {
+	return vm.cores[0].LoadProgram(at, program)
+}
+
+// Observe notifies observer of every access to shared memory bank, by any core
+func (vm *MultiCore) Observe(kind memory.MemoryType, observer memory.Observer) error {
+	return vm.cores[0].Observe(kind, observer)
+}
+
+func (vm *MultiCore) Reset() {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+	for id, core := range vm.cores {
+		core.Reset()
+		vm.running[id] = id == 0
+	}
+	vm.ticks = 0
+	vm.err = nil
+}
+
+// StartCore boots core at address. In parallel scheduling, core started
+// outside of Run gets its goroutine once Run is called.
+func (vm *MultiCore) StartCore(id uint16, at uint16) error {
+	core, err := vm.Core(id)
+	if err != nil {
+		return err
+	}
+
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+	if vm.running[id] {
+		return internal.Error(fmt.Sprintf("core %d already running", id), nil, internal.ErrorCore)
+	}
+	core.Reset()
+	core.cpu.SetRegister(register.Ip, at)
+	core.status = Loaded
+	vm.running[id] = true
+
+	if vm.scheduling == Parallel && vm.active {
+		vm.group.Add(1)
+		go vm.runCore(id, vm.limit)
+	}
+	return nil
+}
+
+func (vm *MultiCore) IsCoreRunning(id uint16) (bool, error) {
+	if _, err := vm.Core(id); err != nil {
+		return false, err
+	}
+
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+	return vm.running[id], nil
+}
+
+// Tick executes one instruction on each running core, in core ID order
+func (vm *MultiCore) Tick() error {
+	for id := range vm.cores {
+		if running, _ := vm.IsCoreRunning(uint16(id)); !running {
+			continue
+		}
+		if err := vm.tickCore(uint16(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vm *MultiCore) tickCore(id uint16) error {
+	core := vm.cores[id]
+	err := core.Tick()
+
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+	if core.IsDone() {
+		vm.running[id] = false
+	}
+	if err != nil {
+		return internal.Error(fmt.Sprintf("error on core %d", id), err, internal.ErrorCore)
+	}
+	return nil
+}
+
+// Run executes the machine until all cores halt, or until each core
+// executes limit ticks, using the configured scheduling.
+func (vm *MultiCore) Run(limit int) (int, error) {
+	if vm.scheduling == Parallel {
+		return vm.runParallel(limit)
+	}
+
+	step := 0
+	for step < limit {
+		if err := vm.Tick(); err != nil {
+			return step, internal.Error(fmt.Sprintf("error at tick %d", step), err, internal.ErrorRuntime)
+		}
+		step++
+		if vm.IsDone() {
+			break
+		}
+	}
+	return step, nil
+}
+
+func (vm *MultiCore) runParallel(limit int) (int, error) {
+	// Cores started meanwhile by StartCore wait for the lock,
+	// so each running core gets exactly one goroutine
+	vm.lock.Lock()
+	vm.limit = limit
+	vm.active = true
+	vm.ticks = 0
+	vm.err = nil
+	for id, running := range vm.running {
+		if running {
+			vm.group.Add(1)
+			go vm.runCore(uint16(id), limit)
+		}
+	}
+	vm.lock.Unlock()
+	vm.group.Wait()
+
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+	vm.active = false
+	if vm.err != nil {
+		return vm.ticks, internal.Error("error running cores in parallel", vm.err, internal.ErrorRuntime)
+	}
+	return vm.ticks, nil
+}
+
+func (vm *MultiCore) runCore(id uint16, limit int) {
+	defer vm.group.Done()
+
+	step := 0
+	for step < limit {
+		err := vm.tickCore(id)
+		step++
+
+		vm.lock.Lock()
+		if step > vm.ticks {
+			vm.ticks = step
+		}
+		if err != nil && vm.err == nil {
+			vm.err = err
+		}
+		failed := vm.err != nil
+		vm.lock.Unlock()
+
+		if err != nil || failed {
+			return
+		}
+		if running, _ := vm.IsCoreRunning(id); !running {
+			return
+		}
+	}
+}
+
+// IsDone reports whether all started cores halted
+func (vm *MultiCore) IsDone() bool {
+	for id := range vm.cores {
+		if running, _ := vm.IsCoreRunning(uint16(id)); running {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package machine
+
+import (
+	"testing"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+func loadMultiCoreProgram(vm *MultiCore) {
+	worker := packProgram(
+		instruction.MOV_REG_REG.Pack(register.Cid.AsUint16(), register.R1.AsUint16()),
+		instruction.MOV_LIT_AC.Pack(20),
+		instruction.ADD_REG_LIT.Pack(register.R1.AsUint16(), 12),
+		instruction.MOV_REG_REG.Pack(register.Ac.AsUint16(), register.R1.AsUint16()),
+		instruction.MOV_LIT_AC.Pack(20),
+		instruction.MOV_REG_MEM.Pack(register.R1.AsUint16()),
+	)
+	main := packProgram(
+		instruction.MOV_LIT_R1.Pack(1),
+		instruction.MOV_LIT_R2.Pack(100),
+		instruction.START_CORE.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+		instruction.WAIT_CORE.Pack(register.R1.AsUint16()),
+		instruction.MOV_LIT_AC.Pack(20),
+		instruction.MOV_MEM_REG.Pack(register.Ac.AsUint16(), register.R3.AsUint16()),
+	)
+	vm.LoadProgram(100, worker)
+	vm.LoadProgram(0, main)
+}
+
+func Test_MultiCore_RoundRobin(t *testing.T) {
+	vm := NewMultiCore(2, 255, RoundRobin)
+	loadMultiCoreProgram(vm)
+
+	steps, err := vm.Run(127)
+	if err != nil || !vm.IsDone() {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+
+	core, _ := vm.Core(0)
+	if core.cpu.GetRegister(register.R3) != 13 {
+		t.Fatalf("expected core 0 to read value set by core 1, got %d", core.cpu.GetRegister(register.R3))
+	}
+
+	worker, _ := vm.Core(1)
+	if worker.cpu.GetRegister(register.Cid) != 1 {
+		t.Fatalf("expected core ID 1, got %d", worker.cpu.GetRegister(register.Cid))
+	}
+	if worker.cpu.GetRegister(register.R3) != 0 {
+		t.Fatalf("expected core registers not to be shared")
+	}
+}
+
+func Test_MultiCore_Parallel(t *testing.T) {
+	vm := NewMultiCore(2, 255, Parallel)
+	loadMultiCoreProgram(vm)
+
+	if steps, err := vm.Run(0xffff); err != nil || !vm.IsDone() {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+
+	core, _ := vm.Core(0)
+	if core.cpu.GetRegister(register.R3) != 13 {
+		t.Fatalf("expected core 0 to read value set by core 1, got %d", core.cpu.GetRegister(register.R3))
+	}
+}
+
+func Test_MultiCore_StartUnknownCore(t *testing.T) {
+	vm := NewMultiCore(2, 255, RoundRobin)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_R1.Pack(3),
+		instruction.START_CORE.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+	))
+
+	if _, err := vm.Run(127); err == nil {
+		t.Fatalf("expected error starting unknown core")
+	}
+}
+
+func Test_Machine_StartCoreWithoutController(t *testing.T) {
+	vm := NewMachine(255)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_R1.Pack(1),
+		instruction.START_CORE.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+	))
+
+	if _, err := run(vm); err == nil {
+		t.Fatalf("expected error starting core on single core machine")
+	}
+}
+
+func Test_MultiCore_Parallel_FetchAdd(t *testing.T) {
+	cores := 4
+	vm := NewMultiCore(cores, 255, Parallel)
+
+	// Each core increments shared counter 10 times
+	worker := packProgram(
+		instruction.MOV_LIT_R1.Pack(1),
+		instruction.MOV_LIT_R3.Pack(10),
+		instruction.MOV_LIT_R4.Pack(104),
+		instruction.MOV_LIT_AC.Pack(20),
+		instruction.FADD.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+		instruction.ADD_REG_LIT.Pack(register.R5.AsUint16(), 1),
+		instruction.MOV_REG_REG.Pack(register.Ac.AsUint16(), register.R5.AsUint16()),
+		instruction.JLT.Pack(register.R3.AsUint16(), register.R4.AsUint16()),
+	)
+	main := packProgram(
+		instruction.MOV_LIT_R1.Pack(1),
+		instruction.MOV_LIT_R2.Pack(100),
+		instruction.START_CORE.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+		instruction.MOV_LIT_R1.Pack(2),
+		instruction.START_CORE.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+		instruction.MOV_LIT_R1.Pack(3),
+		instruction.START_CORE.Pack(register.R1.AsUint16(), register.R2.AsUint16()),
+	)
+	vm.LoadProgram(100, worker)
+	vm.LoadProgram(0, main)
+
+	if steps, err := vm.Run(0xffff); err != nil || !vm.IsDone() {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+
+	core, _ := vm.Core(0)
+	ram, _ := core.getMemory(memory.RAM)
+	if value, _ := ram.GetUint16(20); value != uint16(10*(cores-1)) {
+		t.Fatalf("expected counter to be incremented atomically to %d, got %d", 10*(cores-1), value)
+	}
+}
+
+func Test_MultiCore_Parallel_StartOutsideRun(t *testing.T) {
+	vm := NewMultiCore(2, 255, Parallel)
+	vm.LoadProgram(100, packProgram(
+		instruction.MOV_LIT_R1.Pack(13),
+	))
+	if err := vm.StartCore(1, 100); err != nil {
+		t.Fatalf("unable to start core: %v", err)
+	}
+	if running, _ := vm.IsCoreRunning(1); !running {
+		t.Fatalf("expected core to be marked running")
+	}
+
+	if steps, err := vm.Run(0xffff); err != nil || !vm.IsDone() {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	core, _ := vm.Core(1)
+	if core.cpu.GetRegister(register.R1) != 13 {
+		t.Fatalf("expected core started outside of run to execute once run")
+	}
+}
@@ -0,0 +1,52 @@
+package machine
+
+import (
+	"fmt"
+	"the-machine/machine/cpu"
+	"the-machine/machine/internal"
+)
+
+// preemption raises timer trap after interval cycles spent outside
+// of trap handlers, so slow handlers can't starve the tasks
+type preemption struct {
+	interval uint64
+	elapsed  uint64
+}
+
+func (x *preemption) reset() {
+	x.elapsed = 0
+}
+
+// SetPreemption raises timer trap every interval cycles, so that guest
+// scheduler in the timer trap handler can switch tasks. Zero disables it.
+func (vm *Machine) SetPreemption(interval uint64) {
+	vm.preemption.interval = interval
+	vm.preemption.reset()
+}
+
+// preempt enters timer trap handler when due, unless already in a trap
+func (vm *Machine) preempt(cycles uint64) error {
+	if vm.preemption.interval == 0 || vm.IsDone() || vm.cpu.InTrap() {
+		return nil
+	}
+	vm.preemption.elapsed += cycles
+	if vm.preemption.elapsed < vm.preemption.interval {
+		return nil
+	}
+	vm.preemption.elapsed = 0
+
+	handler, ok := vm.traps[cpu.TrapTimer]
+	if !ok {
+		return nil
+	}
+	if err := vm.cpu.EnterTrap(cpu.TrapTimer, 0, uint16(handler)); err != nil {
+		return internal.Error(fmt.Sprintf("unable to preempt at cycle %d", vm.cpu.GetCycles()), err, internal.ErrorRuntime)
+	}
+	return nil
+}
+
+func (vm *MultiCore) SetPreemption(interval uint64) {
+	for _, core := range vm.cores {
+		core.SetPreemption(interval)
+	}
+}
@@ -0,0 +1,84 @@
+package machine
+
+import (
+	"testing"
+	"the-machine/machine/cpu"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+func Test_Preemption_GuestScheduler(t *testing.T) {
+	const (
+		ctxA    = 0x40
+		ctxB    = 0x60
+		current = 0x80
+	)
+	vm := NewMachine(2048)
+
+	for at, step := range map[uint16]uint16{0x100: 1, 0x200: 2} {
+		vm.LoadProgram(memory.Address(at), packProgram(
+			instruction.ADD_REG_LIT.Pack(register.R7.AsUint16(), step),
+			instruction.MOV_REG_REG.Pack(register.Ac.AsUint16(), register.R7.AsUint16()),
+			instruction.MOV_LIT_R8.Pack(at),
+			instruction.JEQ.Pack(register.Ac.AsUint16(), register.R8.AsUint16()),
+		))
+	}
+
+	// Timer handler: save current task, pick the other one and resume it
+	vm.LoadProgram(0x300, packStatements(instruction.SYSRET,
+		instruction.MOV_LIT_AC.Pack(current),                                         // 768
+		instruction.MOV_MEM_REG.Pack(register.Ac.AsUint16(), register.R3.AsUint16()), // 770
+		instruction.SAVE_CTX.Pack(register.R3.AsUint16()),                            // 772
+		instruction.MOV_LIT_R4.Pack(ctxA),                                            // 774
+		instruction.MOV_LIT_R5.Pack(ctxB),                                            // 776
+		instruction.MOV_REG_REG.Pack(register.R3.AsUint16(), register.Ac.AsUint16()), // 778
+		instruction.MOV_LIT_R6.Pack(790),                                             // 780
+		instruction.JEQ.Pack(register.R4.AsUint16(), register.R6.AsUint16()),         // 782
+		instruction.MOV_REG_REG.Pack(register.R4.AsUint16(), register.R3.AsUint16()), // 784
+		instruction.MOV_LIT_R6.Pack(792),                                             // 786
+		instruction.JEQ.Pack(register.Ac.AsUint16(), register.R6.AsUint16()),         // 788
+		instruction.MOV_REG_REG.Pack(register.R5.AsUint16(), register.R3.AsUint16()), // 790
+		instruction.MOV_LIT_AC.Pack(current),                                         // 792
+		instruction.MOV_REG_MEM.Pack(register.R3.AsUint16()),                         // 794
+		instruction.LOAD_CTX.Pack(register.R3.AsUint16()),                            // 796
+	))
+
+	ram, _ := vm.getMemory(memory.RAM)
+	ram.SetUint16(current, ctxA)
+	ram.SetUint16(memory.Address(ctxB+(len(cpu.ContextRegisters)-1)*2), 0x200) // Ip of task B
+
+	vm.SetTrapHandler(cpu.TrapTimer, 0x300)
+	vm.SetPreemption(20)
+	vm.cpu.SetRegister(register.Ip, 0x100)
+
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running tasks: %v", steps, err)
+	}
+
+	r7 := memory.Address(6 * 2)
+	a, _ := ram.GetUint16(ctxA + r7)
+	b, _ := ram.GetUint16(ctxB + r7)
+	if a == 0 || b == 0 {
+		t.Fatalf("expected both tasks to make progress, got %d and %d", a, b)
+	}
+	if b%2 != 0 {
+		t.Fatalf("expected task B to keep its own register file, got %d", b)
+	}
+}
+
+func Test_Preemption_WithoutHandler(t *testing.T) {
+	vm := NewMachine(255)
+	vm.SetPreemption(1)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_R1.Pack(13),
+		instruction.MOV_LIT_R2.Pack(12),
+	))
+
+	if _, err := run(vm); err != nil {
+		t.Fatalf("expected preemption without handler to be ignored, got %v", err)
+	}
+	if vm.cpu.GetRegister(register.R2) != 12 {
+		t.Fatalf("expected program to run to completion")
+	}
+}
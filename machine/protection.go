@@ -0,0 +1,202 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"the-machine/machine/cpu"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+type Attribute uint8
+
+const (
+	ReadOnly       Attribute = 1 << iota
+	NoExecute      Attribute = 1 << iota
+	SupervisorOnly Attribute = 1 << iota
+)
+
+func (x Attribute) String() string {
+	out := ""
+	if x&ReadOnly != 0 {
+		out += "r"
+	} else {
+		out += "w"
+	}
+	if x&NoExecute != 0 {
+		out += "-"
+	} else {
+		out += "x"
+	}
+	if x&SupervisorOnly != 0 {
+		out += "s"
+	} else {
+		out += "u"
+	}
+	return out
+}
+
+// Protection applies attributes to inclusive address range
+type Protection struct {
+	From       memory.Address
+	To         memory.Address
+	Attributes Attribute
+}
+
+func (x Protection) covers(at memory.Address) bool {
+	return at >= x.From && at <= x.To
+}
+
+type ProtectionMap map[memory.MemoryType][]Protection
+
+// attributesAt combines attributes of all ranges covering address
+func (x ProtectionMap) attributesAt(kind memory.MemoryType, at memory.Address) Attribute {
+	var attrs Attribute
+	for _, p := range x[kind] {
+		if p.covers(at) {
+			attrs |= p.Attributes
+		}
+	}
+	return attrs
+}
+
+type access uint8
+
+const (
+	accessRead    access = 0
+	accessWrite   access = iota
+	accessExecute access = iota
+)
+
+func (x ProtectionMap) check(kind memory.MemoryType, at memory.Address, size int, mode cpu.Mode, acc access) error {
+	for i := 0; i < size; i++ {
+		addr := at + memory.Address(i)
+		attrs := x.attributesAt(kind, addr)
+		if attrs&SupervisorOnly != 0 && mode != cpu.Supervisor {
+			return cpu.RaiseTrap(cpu.TrapProtection, uint16(addr), fmt.Sprintf("%s memory is supervisor-only", kind))
+		}
+		if acc == accessWrite && attrs&ReadOnly != 0 {
+			return cpu.RaiseTrap(cpu.TrapProtection, uint16(addr), fmt.Sprintf("%s memory is read-only", kind))
+		}
+		if acc == accessExecute && attrs&NoExecute != 0 {
+			return cpu.RaiseTrap(cpu.TrapProtection, uint16(addr), fmt.Sprintf("%s memory is not executable", kind))
+		}
+	}
+	return nil
+}
+
+// protectedMemory checks guest accesses against protection attributes,
+// reads are checked as instruction fetches if fetch is set
+type protectedMemory struct {
+	mem        memory.MemoryAccess
+	kind       memory.MemoryType
+	protection ProtectionMap
+	cpu        *cpu.Cpu
+	fetch      bool
+}
+
+func (x protectedMemory) readAccess() access {
+	if x.fetch {
+		return accessExecute
+	}
+	return accessRead
+}
+
+// check applies protection of the bank, or for flat address space,
+// of banks mapped to the bus. Bus is supervisor-only, since it
+// bypasses address translation.
+func (x protectedMemory) check(at memory.Address, size int, acc access) error {
+	mode := x.cpu.GetMode()
+	if x.kind != memory.Flat {
+		return x.protection.check(x.kind, at, size, mode, acc)
+	}
+	if mode != cpu.Supervisor {
+		return cpu.RaiseTrap(cpu.TrapProtection, uint16(at), "flat address space is supervisor-only")
+	}
+	bus, ok := memory.Unwrap(x.mem).(*memory.Bus)
+	if !ok {
+		return nil
+	}
+	for i := 0; i < size; i++ {
+		r, offset, err := bus.Resolve(at + memory.Address(i))
+		if err != nil {
+			continue
+		}
+		if err := x.protection.check(r.Kind, offset, 1, mode, acc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x protectedMemory) GetByte(at memory.Address) (byte, error) {
+	if err := x.check(at, 1, x.readAccess()); err != nil {
+		return 0, err
+	}
+	return x.mem.GetByte(at)
+}
+
+func (x protectedMemory) GetUint16(at memory.Address) (uint16, error) {
+	if err := x.check(at, 2, x.readAccess()); err != nil {
+		return 0, err
+	}
+	return x.mem.GetUint16(at)
+}
+
+func (x protectedMemory) SetByte(at memory.Address, value byte) error {
+	if err := x.check(at, 1, accessWrite); err != nil {
+		return err
+	}
+	return x.mem.SetByte(at, value)
+}
+
+func (x protectedMemory) SetUint16(at memory.Address, value uint16) error {
+	if err := x.check(at, 2, accessWrite); err != nil {
+		return err
+	}
+	return x.mem.SetUint16(at, value)
+}
+
+// Protect applies attributes to memory range in bank, for guest accesses
+func (vm *Machine) Protect(kind memory.MemoryType, from memory.Address, to memory.Address, attrs Attribute) {
+	vm.protection[kind] = append(vm.protection[kind], Protection{From: from, To: to, Attributes: attrs})
+}
+
+// SetTrapHandler routes trap to handler address, running in supervisor mode.
+// Traps without handler stop the machine with an error.
+func (vm *Machine) SetTrapHandler(trap cpu.Trap, handler memory.Address) {
+	vm.traps[trap] = handler
+}
+
+// handleTrap enters trap handler if the error is a handled trap request.
+// Faults resume at ip, the start of the faulting instruction.
+func (vm *Machine) handleTrap(err error, ip uint16) (bool, error) {
+	var request cpu.TrapRequest
+	if !errors.As(err, &request) {
+		return false, nil
+	}
+	handler, ok := vm.traps[request.Trap]
+	if !ok {
+		return false, nil
+	}
+	if request.Trap.IsFault() {
+		vm.cpu.SetRegister(register.Ip, ip)
+	}
+	if err := vm.cpu.EnterTrap(request.Trap, request.Argument, uint16(handler)); err != nil {
+		return false, internal.Error(fmt.Sprintf("unable to handle %s trap", request.Trap), err, internal.ErrorProtection)
+	}
+	return true, nil
+}
+
+func (vm *MultiCore) Protect(kind memory.MemoryType, from memory.Address, to memory.Address, attrs Attribute) {
+	for _, core := range vm.cores {
+		core.Protect(kind, from, to, attrs)
+	}
+}
+
+func (vm *MultiCore) SetTrapHandler(trap cpu.Trap, handler memory.Address) {
+	for _, core := range vm.cores {
+		core.SetTrapHandler(trap, handler)
+	}
+}
@@ -0,0 +1,160 @@
+package machine
+
+import (
+	"testing"
+	"the-machine/machine/cpu"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+// Supervisor at 0 drops to user code at 50
+func newSupervisedMachine(user []byte, handler []byte) Machine {
+	vm := NewMachine(255)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_R1.Pack(50),
+		instruction.ENTER_USER.Pack(register.R1.AsUint16()),
+	))
+	vm.LoadProgram(50, user)
+	vm.LoadProgram(200, handler)
+	vm.Protect(memory.ROM, 0, 49, SupervisorOnly)
+	vm.Protect(memory.ROM, 200, 254, SupervisorOnly)
+	return vm
+}
+
+func Test_Protection_Syscall(t *testing.T) {
+	vm := newSupervisedMachine(
+		packProgram(
+			instruction.MOV_LIT_R2.Pack(161),
+			instruction.SYSCALL.Pack(7),
+			instruction.MOV_LIT_R3.Pack(13),
+		),
+		packProgram(
+			instruction.MOV_REG_REG.Pack(register.R1.AsUint16(), register.R5.AsUint16()),
+			instruction.MOV_REG_REG.Pack(register.R2.AsUint16(), register.R6.AsUint16()),
+			instruction.SYSRET.Pack(),
+		),
+	)
+	vm.SetTrapHandler(cpu.TrapSyscall, 200)
+
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	if vm.cpu.GetRegister(register.R5) != uint16(cpu.TrapSyscall) {
+		t.Fatalf("expected trap kind in R1 for handler, got %d", vm.cpu.GetRegister(register.R5))
+	}
+	if vm.cpu.GetRegister(register.R6) != 7 {
+		t.Fatalf("expected syscall number in R2 for handler, got %d", vm.cpu.GetRegister(register.R6))
+	}
+	if vm.cpu.GetRegister(register.R2) != 161 {
+		t.Fatalf("expected R2 to be restored after trap, got %d", vm.cpu.GetRegister(register.R2))
+	}
+	if vm.cpu.GetRegister(register.R3) != 13 {
+		t.Fatalf("expected execution to resume after syscall")
+	}
+	if vm.cpu.GetMode() != cpu.User {
+		t.Fatalf("expected user mode after return from trap, got %v", vm.cpu.GetMode())
+	}
+}
+
+func Test_Protection_PrivilegedRegister(t *testing.T) {
+	vm := newSupervisedMachine(
+		packProgram(
+			instruction.MOV_LIT_BNK.Pack(uint16(memory.DeviceIO)),
+		),
+		packProgram(),
+	)
+
+	if _, err := run(vm); err == nil {
+		t.Fatalf("expected error writing to privileged register in user mode")
+	}
+	if vm.cpu.GetRegister(register.Bnk) != uint16(memory.RAM) {
+		t.Fatalf("expected bank to stay unchanged, got %d", vm.cpu.GetRegister(register.Bnk))
+	}
+}
+
+func Test_Protection_ReadOnlyMemory(t *testing.T) {
+	vm := newSupervisedMachine(
+		packProgram(
+			instruction.MOV_LIT_AC.Pack(120),
+			instruction.MOV_LIT_MEM.Pack(161),
+		),
+		packProgram(
+			instruction.MOV_REG_REG.Pack(register.R1.AsUint16(), register.R5.AsUint16()),
+			instruction.MOV_REG_REG.Pack(register.R2.AsUint16(), register.R6.AsUint16()),
+		),
+	)
+	vm.Protect(memory.RAM, 100, 199, ReadOnly)
+	vm.SetTrapHandler(cpu.TrapProtection, 200)
+
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	if vm.cpu.GetRegister(register.R5) != uint16(cpu.TrapProtection) {
+		t.Fatalf("expected protection trap, got %d", vm.cpu.GetRegister(register.R5))
+	}
+	if vm.cpu.GetRegister(register.R6) != 120 {
+		t.Fatalf("expected faulting address, got %d", vm.cpu.GetRegister(register.R6))
+	}
+	ram, _ := vm.getMemory(memory.RAM)
+	if value, _ := ram.GetUint16(120); value != 0 {
+		t.Fatalf("expected read-only memory to stay unchanged, got %d", value)
+	}
+}
+
+func Test_Protection_RetryFaultingAccess(t *testing.T) {
+	vm := newSupervisedMachine(
+		packProgram(
+			instruction.MOV_LIT_AC.Pack(120),
+			instruction.MOV_LIT_MEM.Pack(161),
+			instruction.MOV_LIT_R3.Pack(13),
+		),
+		// Redirect the store to writable memory and retry it
+		packStatements(instruction.SYSRET,
+			instruction.MOV_LIT_AC.Pack(150),
+		),
+	)
+	vm.Protect(memory.RAM, 100, 149, ReadOnly)
+	vm.SetTrapHandler(cpu.TrapProtection, 200)
+
+	if steps, err := run(vm); err != nil {
+		t.Fatalf("machine stuck (%d) or error running program: %v", steps, err)
+	}
+	ram, _ := vm.getMemory(memory.RAM)
+	if value, _ := ram.GetUint16(150); value != 161 {
+		t.Fatalf("expected faulting store to be retried after return from trap, got %d", value)
+	}
+	if value, _ := ram.GetUint16(120); value != 0 {
+		t.Fatalf("expected read-only memory to stay unchanged, got %d", value)
+	}
+	if vm.cpu.GetRegister(register.R3) != 13 {
+		t.Fatalf("expected execution to carry on after retried store")
+	}
+}
+
+func Test_Protection_SupervisorOnlyCode(t *testing.T) {
+	vm := newSupervisedMachine(
+		packProgram(
+			instruction.MOV_LIT_R1.Pack(0),
+			instruction.CALL.Pack(register.R1.AsUint16()),
+		),
+		packProgram(),
+	)
+
+	if _, err := run(vm); err == nil {
+		t.Fatalf("expected error executing supervisor code in user mode")
+	}
+}
+
+func Test_Protection_NoExecute(t *testing.T) {
+	vm := NewMachine(255)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_R1.Pack(100),
+		instruction.CALL.Pack(register.R1.AsUint16()),
+	))
+	vm.Protect(memory.ROM, 100, 199, NoExecute)
+
+	if _, err := run(vm); err == nil {
+		t.Fatalf("expected error executing non-executable memory")
+	}
+}
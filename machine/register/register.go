@@ -57,6 +57,24 @@ var Bnk = Register{
 	pos:         11,
 }
 
+var Cid = Register{
+	description: "Core ID",
+	name:        "Cid",
+	pos:         10,
+}
+
+var Cyc = Register{
+	description: "Cycle Counter",
+	name:        "Cyc",
+	pos:         9,
+}
+
+var Ptb = Register{
+	description: "Page Table Base",
+	name:        "Ptb",
+	pos:         8,
+}
+
 var R1 = Register{
 	description: "Register #1",
 	name:        "R1",
@@ -117,6 +135,12 @@ func FromByte(b byte) (Register, error) {
 		return Ac, nil
 	case Bnk.pos:
 		return Bnk, nil
+	case Cid.pos:
+		return Cid, nil
+	case Cyc.pos:
+		return Cyc, nil
+	case Ptb.pos:
+		return Ptb, nil
 	case R1.pos:
 		return R1, nil
 	case R2.pos:
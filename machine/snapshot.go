@@ -0,0 +1,86 @@
+package machine
+
+import (
+	"fmt"
+	"the-machine/machine/cpu"
+	"the-machine/machine/internal"
+	"the-machine/machine/memory"
+)
+
+// Snapshot is machine state captured at a point in time: cpu, status
+// and contents of every bank backed by plain memory.
+// Devices and host-backed banks, such as persisted files, are left out.
+type Snapshot struct {
+	cpu    *cpu.Cpu
+	banks  map[memory.MemoryType]memory.MemoryAccess
+	status Status
+	cycle  Cycle
+}
+
+// Snapshot captures machine state, with memory copied on write,
+// so that it is cheap enough to take on every tick
+func (vm *Machine) Snapshot() *Snapshot {
+	vm.memoryLock.Lock()
+	defer vm.memoryLock.Unlock()
+	banks := map[memory.MemoryType]memory.MemoryAccess{}
+	for kind, mem := range vm.memory {
+		switch bank := memory.Unwrap(mem).(type) {
+		case *memory.Paged:
+			banks[kind] = bank.Snapshot()
+		case *memory.Rom:
+			banks[kind] = bank.Snapshot()
+		case *memory.Memory:
+			copied := append(memory.Memory{}, *bank...)
+			banks[kind] = &copied
+		}
+	}
+	return &Snapshot{
+		cpu:    vm.cpu.Snapshot(),
+		banks:  banks,
+		status: vm.status,
+		cycle:  vm.cycle,
+	}
+}
+
+// Restore puts machine back into snapshot state.
+// Banks are restored in place, so the bus and observers keep seeing them.
+func (vm *Machine) Restore(snapshot *Snapshot) error {
+	vm.memoryLock.Lock()
+	defer vm.memoryLock.Unlock()
+	for kind, saved := range snapshot.banks {
+		mem, ok := vm.memory[kind]
+		if !ok {
+			return internal.Error(fmt.Sprintf("unable to restore %s, no such bank", kind), nil, internal.ErrorRuntime)
+		}
+		if err := restoreBank(memory.Unwrap(mem), saved); err != nil {
+			return internal.Error(fmt.Sprintf("unable to restore %s", kind), err, internal.ErrorRuntime)
+		}
+	}
+	vm.cpu.Restore(snapshot.cpu)
+	vm.status = snapshot.status
+	vm.cycle = snapshot.cycle
+	// Page tables in RAM may have changed under cached translations
+	if vm.mmu != nil {
+		vm.mmu.Flush()
+	}
+	return nil
+}
+
+func restoreBank(mem memory.MemoryAccess, saved memory.MemoryAccess) error {
+	switch bank := mem.(type) {
+	case *memory.Paged:
+		if from, ok := saved.(*memory.Paged); ok {
+			return bank.Restore(from)
+		}
+	case *memory.Rom:
+		if from, ok := saved.(*memory.Rom); ok {
+			return bank.Restore(from)
+		}
+	case *memory.Memory:
+		if from, ok := saved.(*memory.Memory); ok && len(*from) == len(*bank) {
+			copy(*bank, *from)
+			return nil
+		}
+	}
+	return internal.Error("bank was replaced since snapshot", nil, internal.ErrorMemory)
+}
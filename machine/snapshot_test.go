@@ -0,0 +1,69 @@
+package machine
+
+import (
+	"testing"
+	"the-machine/machine/instruction"
+	"the-machine/machine/memory"
+	"the-machine/machine/register"
+)
+
+func Test_Snapshot_Restore(t *testing.T) {
+	vm := NewMachine(0xffff)
+	vm.LoadProgram(0, packProgram(
+		instruction.MOV_LIT_R1.Pack(13),
+		instruction.MOV_LIT_AC.Pack(1000),
+		instruction.MOV_REG_MEM.Pack(register.R1.AsUint16()),
+		instruction.PUSH_REG.Pack(register.R1.AsUint16()),
+		instruction.MOV_LIT_R1.Pack(161),
+		instruction.MOV_REG_MEM.Pack(register.R1.AsUint16()),
+		instruction.PUSH_REG.Pack(register.R1.AsUint16()),
+	))
+	for i := 0; i < 4; i++ {
+		vm.Tick()
+	}
+	snapshot := vm.Snapshot()
+
+	if _, err := run(vm); err != nil {
+		t.Fatalf("expected program to finish, got %v", err)
+	}
+	vm.LoadProgram(8, packProgram())
+
+	if err := vm.Restore(snapshot); err != nil {
+		t.Fatalf("unable to restore snapshot: %v", err)
+	}
+	check := func(when string) {
+		ram, _ := vm.GetBank(memory.RAM)
+		if x, _ := ram.GetUint16(1000); x != 13 {
+			t.Fatalf("%s: expected RAM to be restored, got %d", when, x)
+		}
+		if x := vm.cpu.GetRegister(register.R1); x != 13 {
+			t.Fatalf("%s: expected registers to be restored, got R1 %d", when, x)
+		}
+		if x := vm.cpu.GetRegister(register.Ip); x != 8 {
+			t.Fatalf("%s: expected Ip to be restored, got %d", when, x)
+		}
+		if size, _ := vm.cpu.GetStack(); size != 1 {
+			t.Fatalf("%s: expected stack to be restored, got %d values", when, size)
+		}
+		if vm.IsDone() {
+			t.Fatalf("%s: expected machine to be running again", when)
+		}
+	}
+	check("restored")
+
+	if _, err := run(vm); err != nil {
+		t.Fatalf("unable to resume from snapshot: %v", err)
+	}
+	if x, _ := vm.cpu.Pop(); x != 161 {
+		t.Fatalf("expected resumed program to push 161, got %d", x)
+	}
+	if err := vm.Restore(snapshot); err != nil {
+		t.Fatalf("unable to restore snapshot again: %v", err)
+	}
+	check("restored again")
+
+	vm.AttachMemory(memory.RAM, memory.NewMemory(16))
+	if err := vm.Restore(snapshot); err == nil {
+		t.Fatalf("expected error restoring into replaced bank")
+	}
+}
@@ -1,16 +1,78 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
+	"strings"
 	"the-machine/cmd"
 	"the-machine/machine"
+	"the-machine/machine/device"
 )
 
+// descriptors collects repeated -fd flags
+type descriptors []cmd.DescriptorConfig
+
+func (x *descriptors) String() string {
+	specs := make([]string, len(*x))
+	for i, fd := range *x {
+		specs[i] = fd.String()
+	}
+	return strings.Join(specs, " ")
+}
+
+func (x *descriptors) Set(spec string) error {
+	fd, err := cmd.ParseDescriptor(spec)
+	if err != nil {
+		return err
+	}
+	*x = append(*x, fd)
+	return nil
+}
+
 func main() {
-	if len(os.Args) > 1 {
-		fname := os.Args[1]
+	var fds descriptors
+	flag.Var(&fds, "fd", "map host file to guest descriptor as fd=path:mode, mode r or w, path - for stdio (repeatable)")
+	heatmap := flag.Bool("heatmap", false, "render memory access heatmap after the run")
+	heatmapCsv := flag.String("heatmap-csv", "", "export memory access counters to CSV file")
+	screenshot := flag.String("screenshot", "", "export video frame to PNG file after the run")
+	mkdisk := flag.String("mkdisk", "", "create blank disk image file and exit")
+	sectors := flag.Int("sectors", 256, "number of sectors for -mkdisk")
+	config := flag.String("config", "", "build machine from JSON config file")
+	hz := flag.Uint64("hz", 0, "throttle execution to cycles per second, 0 for host speed")
+	flag.Parse()
+
+	if *mkdisk != "" {
+		if err := device.CreateDiskImage(*mkdisk, *sectors); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var opts []cmd.Option
+	if *heatmap {
+		opts = append(opts, cmd.WithHeatmap())
+	}
+	if *heatmapCsv != "" {
+		opts = append(opts, cmd.WithHeatmapCSV(*heatmapCsv))
+	}
+	if *screenshot != "" {
+		opts = append(opts, cmd.WithScreenshot(*screenshot))
+	}
+	if *hz > 0 {
+		opts = append(opts, cmd.WithFrequency(*hz))
+	}
+	if len(fds) > 0 {
+		opts = append(opts, cmd.WithDescriptors(fds...))
+	}
+
+	if *config != "" {
+		cmd.RunConfig(*config, opts...)
+	} else if flag.NArg() > 0 {
+		fname := flag.Arg(0)
 		// TODO: validate fname
-		cmd.RunFile(fname)
+		cmd.RunFile(fname, opts...)
 	} else {
 		main_InteractiveDebugger()
 	}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"the-machine/cmd"
 	"the-machine/machine"
+	"the-machine/machine/cpu"
 	"the-machine/machine/debug"
 	"the-machine/machine/device"
 	"the-machine/machine/instruction"
@@ -146,6 +147,42 @@ func main_Microservice() {
 	http.ListenAndServe(":6660", nil)
 }
 
+func main_SupervisedStdout() {
+	vm := machine.NewMachine(1024)
+
+	// Syscall handler: write R3 to stdout
+	handler := packStatements(instruction.SYSRET,
+		instruction.MOV_REG_REG.Pack(register.Bnk.AsUint16(), register.R4.AsUint16()),
+		instruction.MOV_LIT_BNK.Pack(uint16(memory.DeviceIO)),
+		instruction.MOV_LIT_AC.Pack(uint16(device.Stdout)),
+		instruction.MOV_REG_MEM.Pack(register.R3.AsUint16()),
+		instruction.MOV_REG_REG.Pack(register.R4.AsUint16(), register.Bnk.AsUint16()),
+	)
+	user := packProgram(
+		instruction.MOV_LIT_R3.Pack(uint16('o')),
+		instruction.SYSCALL.Pack(1),
+		instruction.MOV_LIT_R3.Pack(uint16('k')),
+		instruction.SYSCALL.Pack(1),
+		instruction.MOV_LIT_R3.Pack(uint16('\n')),
+		instruction.SYSCALL.Pack(1),
+	)
+	supervisor := packProgram(
+		instruction.MOV_LIT_R1.Pack(100),
+		instruction.ENTER_USER.Pack(register.R1.AsUint16()),
+	)
+
+	vm.LoadProgram(0, supervisor)
+	vm.LoadProgram(100, user)
+	vm.LoadProgram(500, handler)
+	vm.Protect(memory.ROM, 0, 99, machine.SupervisorOnly)
+	vm.Protect(memory.ROM, 500, 1023, machine.SupervisorOnly)
+	vm.SetTrapHandler(cpu.TrapSyscall, 500)
+
+	if _, err := cmd.Run(vm); err != nil {
+		vm.DebugError(err)
+	}
+}
+
 func main_RemapStdio_CopyToStdout() {
 	vm := machine.NewMachine(2048)
 	io, err := vm.GetIO()